@@ -2,8 +2,8 @@ package montoya
 
 import (
 	"bytes"
+	"fmt"
 	"io"
-	"slices"
 )
 
 // An IniLine is any line type in an Inifile
@@ -21,11 +21,64 @@ type IniLine interface {
 	SetNext(node IniLine)
 	// SetPrev sets the previous line
 	SetPrev(node IniLine)
+	// Span returns the location of the line in the source, excluding its newline
+	Span() Span
+
+	// base returns the embedded LineBase
+	base() *LineBase
+}
+
+// Position is a location in the parsed source
+type Position struct {
+	// Line is the line number, starting at 1
+	Line int
+	// Column is the byte offset in the line, starting at 1
+	Column int
+	// Offset is the byte offset from the start of the source, starting at 0
+	Offset int
+}
+
+// String formats the position as line:column
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// advance returns the position directly after `content`, when `content`
+// starts at `p`
+func (p Position) advance(content []byte) Position {
+	for _, b := range content {
+		p.Offset += 1
+		if b == B_NEWLINE {
+			p.Line += 1
+			p.Column = 1
+		} else {
+			p.Column += 1
+		}
+	}
+	return p
+}
+
+// Span is a range in the parsed source
+//
+// Lines and nodes created or edited after parsing keep the span they were
+// parsed with, which is zero for anything not parsed from the source.
+type Span struct {
+	// Start is the position of the first byte
+	Start Position
+	// End is the position directly after the last byte
+	End Position
+}
+
+// String formats the span as line:column-line:column
+func (s Span) String() string {
+	return fmt.Sprintf("%v-%v", s.Start, s.End)
 }
 
 // An IniNode is part of an IniLine
 type IniNode interface {
 	Content() []byte
+	// Span returns the location of the node in the source
+	Span() Span
 }
 
 // LineBase is the base struct for iniLines and forms a node in a doubly linked list
@@ -39,6 +92,28 @@ type LineBase struct {
 	prev IniLine
 	// next is a link to the next iniLine
 	next IniLine
+
+	// newline indicates whether the line was terminated by a newline
+	newline bool
+	// span is the location of the line in the source
+	span Span
+}
+
+// Span returns the location of the line in the source, excluding its newline
+func (l *LineBase) Span() Span {
+	return l.span
+}
+
+// base returns the LineBase itself, giving access to the shared line state
+func (l *LineBase) base() *LineBase {
+	return l
+}
+
+// appendNewline appends the line terminator, if any, to the read buffer
+func (l *LineBase) appendNewline() {
+	if l.newline {
+		l.ReadBuf = append(l.ReadBuf, B_NEWLINE)
+	}
 }
 
 // Reset resets reader state
@@ -83,10 +158,23 @@ func (l *LineBase) SetNext(node IniLine) {
 // WhitespaceNode is whitespace in an IniLine
 type WhitespaceNode struct {
 	content []byte
+	// span is the location of the node in the source
+	span Span
+}
+
+// Span returns the location of the node in the source
+func (w *WhitespaceNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
 }
 
 // Content returns the node's content
 func (w *WhitespaceNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
 	return w.content
 }
 
@@ -96,32 +184,175 @@ type CommentNode struct {
 	symbol byte
 	// content contains the comment content, without the comment start symbol
 	content []byte
+	// span is the location of the node in the source, including the start symbol
+	span Span
+}
+
+// Span returns the location of the comment in the source, including the start symbol
+func (w *CommentNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
 }
 
 // Content returns the node's content
 func (w *CommentNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
 	return w.content
 }
 
+// Symbol returns the symbol that started the comment
+func (w *CommentNode) Symbol() byte {
+	return w.symbol
+}
+
+// Raw returns the comment as it appeared in the source, including the start symbol
+func (w *CommentNode) Raw() []byte {
+	if w == nil {
+		return nil
+	}
+	return append([]byte{w.symbol}, w.content...)
+}
+
 // HeaderNode is a header in an IniLine denoting a section
 type HeaderNode struct {
 	// content contains the header name, without brackets
 	content []byte
+	// span is the location of the name in the source, without brackets
+	span Span
+}
+
+// Span returns the location of the name in the source, without brackets
+func (w *HeaderNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
 }
 
 // Content returns the node's content
 func (w *HeaderNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
+	return w.content
+}
+
+// SubsectionNode is a quoted subsection name in a SectionHeaderLine, see
+// `Options.Subsections`
+type SubsectionNode struct {
+	// content contains the subsection name as it appears in the source,
+	// including quotes and escapes
+	content []byte
+	// span is the location of the node in the source, including quotes
+	span Span
+}
+
+// Span returns the location of the subsection in the source, including quotes
+func (w *SubsectionNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
+}
+
+// Content returns the node's content, including quotes and escapes
+func (w *SubsectionNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
 	return w.content
 }
 
+// Value returns the subsection name, without quotes and with escapes decoded
+func (w *SubsectionNode) Value() string {
+	if w == nil || len(w.content) < 2 {
+		return ""
+	}
+	return unescapeSubsection(w.content[1 : len(w.content)-1])
+}
+
+// closed checks if the closing quote of the subsection has been parsed
+func (w *SubsectionNode) closed() bool {
+	for i := 1; i < len(w.content); i++ {
+		switch w.content[i] {
+		case B_BACKSLASH:
+			i++
+		case B_QUOTE:
+			return true
+		}
+	}
+	return false
+}
+
+// LocaleNode is a locale in brackets after the key of a KeyValueLine, see
+// `Options.LocaleKeys`
+type LocaleNode struct {
+	// content contains the locale as it appears in the source, including brackets
+	content []byte
+	// span is the location of the node in the source, including brackets
+	span Span
+}
+
+// Span returns the location of the locale in the source, including brackets
+func (w *LocaleNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
+}
+
+// Content returns the node's content, including brackets
+func (w *LocaleNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
+	return w.content
+}
+
+// Value returns the locale, without brackets
+func (w *LocaleNode) Value() string {
+	if w == nil || len(w.content) < 2 {
+		return ""
+	}
+	return string(w.content[1 : len(w.content)-1])
+}
+
+// closed checks if the closing bracket of the locale has been parsed
+func (w *LocaleNode) closed() bool {
+	n := len(w.content)
+	return n > 1 && w.content[n-1] == B_BRACKETCLOSE
+}
+
 // KeyNode contains a key in a KeyValueLine
 type KeyNode struct {
 	// content contains the key content
 	content []byte
+	// span is the location of the key in the source
+	span Span
+}
+
+// Span returns the location of the key in the source
+func (w *KeyNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
+}
+
+// Position returns the position of the key in the source
+func (w *KeyNode) Position() Position {
+	return w.Span().Start
 }
 
 // Content returns the node's content
 func (w *KeyNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
 	return w.content
 }
 
@@ -129,13 +360,50 @@ func (w *KeyNode) Content() []byte {
 type ValueNode struct {
 	// content contains the value
 	content []byte
+	// span is the location of the value in the source, starting directly after the delimiter
+	span Span
+	// syntax decides how the content is parsed and decoded
+	syntax valueSyntax
+}
+
+// valueSyntax decides how the content of a value is parsed and decoded
+type valueSyntax int
+
+const (
+	standardValue    valueSyntax = iota // quoted or unquoted, see `nextValueState`
+	embeddedValue                       // with quoted parts anywhere, see `Options.EmbeddedQuotes`
+	rawValue                            // verbatim, continued after a backslash, see `Options.RawValues`
+	rawIndentedValue                    // verbatim, continued on indented lines only, see `Options.IndentedContinuation`
+	rawLineValue                        // verbatim, never continued, see `Options.RawValues`
+)
+
+// Span returns the location of the value in the source, starting directly
+// after the delimiter
+func (w *ValueNode) Span() Span {
+	if w == nil {
+		return Span{}
+	}
+	return w.span
+}
+
+// Position returns the position of the value in the source
+func (w *ValueNode) Position() Position {
+	return w.Span().Start
 }
 
 // Content returns the node's content
-func (w ValueNode) Content() []byte {
+func (w *ValueNode) Content() []byte {
+	if w == nil {
+		return nil
+	}
 	return w.content
 }
 
+// state returns the state of the value, see `nextValueState`
+func (w *ValueNode) state() int {
+	return contentState(w.content, w.syntax)
+}
+
 // EmptyLine is an iniLine containing an optional comment
 type EmptyLine struct {
 	LineBase
@@ -148,8 +416,9 @@ type EmptyLine struct {
 func (l *EmptyLine) Read(p []byte) (n int, err error) {
 	if !l.HasReader() {
 		// Populate buffer
-		l.ReadBuf = append(l.ReadBuf, l.Padding.content...)
-		l.ReadBuf = append(l.ReadBuf, l.Comment.content...)
+		l.ReadBuf = append(l.ReadBuf, l.Padding.Content()...)
+		l.ReadBuf = append(l.ReadBuf, l.Comment.Raw()...)
+		l.appendNewline()
 	}
 	return l.LineBase.Read(p)
 }
@@ -165,18 +434,30 @@ type SectionHeaderLine struct {
 
 	Padding *WhitespaceNode
 	Header  *HeaderNode
-	PostPad *WhitespaceNode
-	Comment *CommentNode
+	// SubsectionPad and Subsection are only present for a header with a
+	// subsection, see `Options.Subsections`
+	SubsectionPad *WhitespaceNode
+	Subsection    *SubsectionNode
+	PostPad       *WhitespaceNode
+	Comment       *CommentNode
 }
 
 // Read implements io.Reader for `SectionHeaderLine`
 func (l *SectionHeaderLine) Read(p []byte) (n int, err error) {
 	if !l.HasReader() {
 		// Populate buffer
-		l.ReadBuf = append(l.ReadBuf, l.Padding.content...)
-		l.ReadBuf = append(l.ReadBuf, l.Header.content...)
-		l.ReadBuf = append(l.ReadBuf, l.PostPad.content...)
-		l.ReadBuf = append(l.ReadBuf, l.Comment.content...)
+		l.ReadBuf = append(l.ReadBuf, l.Padding.Content()...)
+		l.ReadBuf = append(l.ReadBuf, B_BRACKET) // [
+		l.ReadBuf = append(l.ReadBuf, l.Header.Content()...)
+		l.ReadBuf = append(l.ReadBuf, l.SubsectionPad.Content()...)
+		l.ReadBuf = append(l.ReadBuf, l.Subsection.Content()...)
+		if l.PostPad != nil {
+			// the closing bracket is only present when the header was terminated
+			l.ReadBuf = append(l.ReadBuf, B_BRACKETCLOSE) // ]
+			l.ReadBuf = append(l.ReadBuf, l.PostPad.Content()...)
+		}
+		l.ReadBuf = append(l.ReadBuf, l.Comment.Raw()...)
+		l.appendNewline()
 	}
 	return l.LineBase.Read(p)
 }
@@ -191,23 +472,59 @@ func (l *SectionHeaderLine) Terminated() bool {
 type KeyValueLine struct {
 	LineBase
 
-	Padding    *WhitespaceNode
-	Key        *KeyNode
+	Padding *WhitespaceNode
+	Key     *KeyNode
+	// Locale is only present for a localized key, see `Options.LocaleKeys`
+	Locale     *LocaleNode
 	PostKeyPad *WhitespaceNode
 	Value      *ValueNode
 	Comment    *CommentNode
+
+	// delimiter separates the key from the value, zero for the default `=`
+	delimiter byte
+	// syntax is the syntax of the values in the file, which a bare key uses
+	// once it gains a value
+	syntax valueSyntax
+}
+
+// Delimiter returns the byte separating the key from the value
+func (l *KeyValueLine) Delimiter() byte {
+	if l.delimiter == 0 {
+		return B_EQUALS
+	}
+	return l.delimiter
+}
+
+// HasValue reports whether the line has a value, which a bare key lacks, see
+// `Options.BareKeys`
+func (l *KeyValueLine) HasValue() bool {
+	return l.Value != nil
+}
+
+// valuePosition returns the position of the value in the source, or of the
+// key for a bare key
+func (l *KeyValueLine) valuePosition() Position {
+	if l.Value == nil {
+		return l.Key.Position()
+	}
+	return l.Value.Position()
 }
 
 // Read implements io.Reader for `KeyValueLine`
 func (l *KeyValueLine) Read(p []byte) (n int, err error) {
 	if !l.HasReader() {
 		// Populate buffer
-		l.ReadBuf = append(l.ReadBuf, l.Padding.content...)
-		l.ReadBuf = append(l.ReadBuf, l.Key.content...)
-		l.ReadBuf = append(l.ReadBuf, l.PostKeyPad.content...)
-		l.ReadBuf = append(l.ReadBuf, B_EQUALS) // =
-		l.ReadBuf = append(l.ReadBuf, l.Value.content...)
-		l.ReadBuf = append(l.ReadBuf, l.Comment.content...)
+		l.ReadBuf = append(l.ReadBuf, l.Padding.Content()...)
+		l.ReadBuf = append(l.ReadBuf, l.Key.Content()...)
+		l.ReadBuf = append(l.ReadBuf, l.Locale.Content()...)
+		l.ReadBuf = append(l.ReadBuf, l.PostKeyPad.Content()...)
+		if l.Value != nil {
+			// the parser only creates a value after seeing a delimiter
+			l.ReadBuf = append(l.ReadBuf, l.Delimiter())
+			l.ReadBuf = append(l.ReadBuf, l.Value.Content()...)
+		}
+		l.ReadBuf = append(l.ReadBuf, l.Comment.Raw()...)
+		l.appendNewline()
 	}
 	return l.LineBase.Read(p)
 }
@@ -215,28 +532,65 @@ func (l *KeyValueLine) Read(p []byte) (n int, err error) {
 // Terminated indicates whether a KeyValueLine was properly terminated
 func (l *KeyValueLine) Terminated() bool {
 	if l.Value == nil {
-		// parser never saw B_EQUALS
+		// parser never saw a delimiter
 		return false
 	}
 
-	state := valueStringState(l.Value.content)
-	return (state == VALUE_PARSE_WHITESPACE || state == VALUE_PARSE_QUOTED_TERMINATED || state == VALUE_PARSE_UNQUOTED)
+	state := l.Value.state()
+	return (state == VALUE_PARSE_WHITESPACE || state == VALUE_PARSE_QUOTED_TERMINATED || state == VALUE_PARSE_UNQUOTED || state == VALUE_PARSE_EMBEDDED || state == VALUE_PARSE_RAW)
+}
+
+// InvalidLine is an iniLine that failed to parse, kept verbatim
+//
+// It is only produced when parsing with `Options.Recover`.
+type InvalidLine struct {
+	LineBase
+
+	// Raw holds the line as it appeared in the source, without its newline
+	Raw []byte
+	// Err is the diagnostic reported for the line
+	Err *ParseError
+}
+
+// Read implements io.Reader for `InvalidLine`
+func (l *InvalidLine) Read(p []byte) (n int, err error) {
+	if !l.HasReader() {
+		// Populate buffer
+		l.ReadBuf = append(l.ReadBuf, l.Raw...)
+		l.appendNewline()
+	}
+	return l.LineBase.Read(p)
+}
+
+// Terminated is always true for an InvalidLine, its content is never interpreted
+func (l *InvalidLine) Terminated() bool {
+	return true
 }
 
 // isKeyByte checks if the input may be present in a Key
 func isKeyByte(input byte) bool {
-	return slices.Contains(validKeyByteSet, input)
+	return keyByteTable[input]
+}
+
+// isLocaleByte checks if the input may be present in the locale of a key
+func isLocaleByte(input byte) bool {
+	return localeByteTable[input]
 }
 
-// inQuotedString returns if `content` contains a currently unterminated quoted string
-func inQuotedString(content []byte) bool {
-	state := valueStringState(content)
-	return state == VALUE_PARSE_QUOTED || state == VALUE_PARSE_QUOTED_BACKSLASH
+// inQuotedString returns if `state` is that of a currently unterminated quoted string
+func inQuotedString(state int) bool {
+	return state == VALUE_PARSE_QUOTED || state == VALUE_PARSE_QUOTED_BACKSLASH || inTripleQuotedString(state) ||
+		state == VALUE_PARSE_EMBEDDED_QUOTED || state == VALUE_PARSE_EMBEDDED_QUOTED_BACKSLASH
 }
 
-// isClosedQuotedString returns if `content` contains a quoted string that has been closed
-func isClosedQuotedString(content []byte) bool {
-	state := valueStringState(content)
+// inTripleQuotedString returns if `state` is that of a currently unterminated
+// triple quoted string
+func inTripleQuotedString(state int) bool {
+	return state == VALUE_PARSE_TRIPLE_QUOTED || state == VALUE_PARSE_TRIPLE_QUOTED_1 || state == VALUE_PARSE_TRIPLE_QUOTED_2
+}
+
+// isClosedQuotedString returns if `state` is that of a quoted string that has been closed
+func isClosedQuotedString(state int) bool {
 	return state == VALUE_PARSE_QUOTED_TERMINATED
 }
 
@@ -244,83 +598,221 @@ const VALUE_PARSE_WHITESPACE = 1        // The value consists entirely of whites
 const VALUE_PARSE_QUOTED = 2            // The value is an open, quoted string
 const VALUE_PARSE_QUOTED_BACKSLASH = 3  // The value is an open, quoted string and the last byte is an escape backslash
 const VALUE_PARSE_QUOTED_TERMINATED = 4 // The Value is a terminated quoted string
+const VALUE_PARSE_TRIPLE_QUOTED = 5     // The value is an open, triple quoted string
+const VALUE_PARSE_TRIPLE_QUOTED_1 = 6   // The value is an open, triple quoted string and the last byte is a quote
+const VALUE_PARSE_TRIPLE_QUOTED_2 = 7   // The value is an open, triple quoted string and the last two bytes are quotes
 const VALUE_PARSE_UNQUOTED = 9          // The value is an unquoted string
 const VALUE_PARSE_ERROR = 10            // The value contains illegal bytes
 
+// States of values with embedded quotes, see `Options.EmbeddedQuotes`
+const VALUE_PARSE_EMBEDDED = 11                  // The value is outside of quotes
+const VALUE_PARSE_EMBEDDED_BACKSLASH = 12        // The value is outside of quotes and the last byte is an escape backslash
+const VALUE_PARSE_EMBEDDED_QUOTED = 13           // The value is inside quotes
+const VALUE_PARSE_EMBEDDED_QUOTED_BACKSLASH = 14 // The value is inside quotes and the last byte is an escape backslash
+
+// VALUE_PARSE_RAW is the state of a raw value, see `Options.RawValues`
+const VALUE_PARSE_RAW = 15
+
+// tripleQuote opens and closes a triple quoted value
+var tripleQuote = []byte{B_QUOTE, B_QUOTE, B_QUOTE}
+
 // valuestringState uses a state machine to determine the state of the currently parsed value string
+//
+// Backslash line continuations are skipped, unless the backslash is escaped.
 func valueStringState(content []byte) (state int) {
 	state = VALUE_PARSE_WHITESPACE
-	for _, token := range content {
+	if trimmed := bytes.TrimLeft(content, string(validWhitespaceByteSet)); bytes.HasPrefix(trimmed, tripleQuote) {
+		state = VALUE_PARSE_TRIPLE_QUOTED
+		content = trimmed[len(tripleQuote):]
+	}
+	for i := 0; i < len(content); i++ {
+		if n := continuationLength(content[i:]); n > 0 && state != VALUE_PARSE_QUOTED_BACKSLASH && !inTripleQuotedString(state) {
+			i += n - 1
+			continue
+		}
+		state = nextValueState(state, content[i])
+		if state == VALUE_PARSE_ERROR {
+			return
+		}
+	}
+	return
+}
+
+// embeddedStringState determines the state of a value with embedded quotes,
+// see `Options.EmbeddedQuotes`
+//
+// Backslash line continuations need no skipping, as the backslash escapes the newline.
+func embeddedStringState(content []byte) (state int) {
+	state = VALUE_PARSE_EMBEDDED
+	for i := 0; i < len(content) && state != VALUE_PARSE_ERROR; i++ {
+		state = nextValueState(state, content[i])
+	}
+	return
+}
+
+// contentState determines the state of value content in the given syntax
+func contentState(content []byte, syntax valueSyntax) int {
+	switch syntax {
+	case embeddedValue:
+		return embeddedStringState(content)
+	case rawValue, rawIndentedValue, rawLineValue:
+		if bytes.IndexByte(content, B_NULL) >= 0 {
+			return VALUE_PARSE_ERROR
+		}
+		return VALUE_PARSE_RAW
+	}
+	return valueStringState(content)
+}
+
+// continuationLength returns the length of the backslash line continuation
+// at the start of `content`, or 0 if there is none
+func continuationLength(content []byte) int {
+	if len(content) < 2 || content[0] != B_BACKSLASH {
+		return 0
+	}
+	if content[1] == B_NEWLINE {
+		return 2
+	}
+	if len(content) > 2 && content[1] == B_CR && content[2] == B_NEWLINE {
+		return 3
+	}
+	return 0
+}
+
+// nextValueState advances the value state machine by a single byte
+//
+// The parser calls this for every byte appended to a value, so the state of
+// the value being parsed is always known without rescanning it. Comment
+// symbols are valid in unquoted values here, as the parser decides whether they
+// start an inline comment instead.
+func nextValueState(state int, token byte) int {
+	if token == B_NEWLINE {
+		// a value only holds a newline when it continues on the next line,
+		// which unquoted and triple quoted values may do
 		switch state {
-		// We are currently still parsing whitespace
-		case VALUE_PARSE_WHITESPACE:
-			switch convertToken(token) {
-			// Encounter another whitespace
-			case Whitespace:
-				continue
-			// The string opens
-			case Quote:
-				state = VALUE_PARSE_QUOTED
-			default:
-				// The string opens unquoted, check if the token is valid
-				if slices.Contains(validValueByteSetUnquoted, token) {
-					state = VALUE_PARSE_UNQUOTED
-				} else {
-					// this character is not allowed
-					return VALUE_PARSE_ERROR
-				}
-			}
+		case VALUE_PARSE_WHITESPACE, VALUE_PARSE_UNQUOTED, VALUE_PARSE_QUOTED_TERMINATED:
+			return state
+		case VALUE_PARSE_TRIPLE_QUOTED, VALUE_PARSE_TRIPLE_QUOTED_1, VALUE_PARSE_TRIPLE_QUOTED_2:
+			return VALUE_PARSE_TRIPLE_QUOTED
+		case VALUE_PARSE_EMBEDDED, VALUE_PARSE_EMBEDDED_BACKSLASH:
+			return VALUE_PARSE_EMBEDDED
+		case VALUE_PARSE_RAW:
+			return state
+		case VALUE_PARSE_EMBEDDED_QUOTED_BACKSLASH:
+			return VALUE_PARSE_EMBEDDED_QUOTED
+		}
+		return VALUE_PARSE_ERROR
+	}
 
-		case VALUE_PARSE_QUOTED:
-			// Start escaping on a backslash, terminate on a quote
-			if token == B_BACKSLASH {
-				state = VALUE_PARSE_QUOTED_BACKSLASH
-				break
-			}
-			if token == B_QUOTE {
-				state = VALUE_PARSE_QUOTED_TERMINATED
-				break
-			}
-			if slices.Contains(invalidValueByteSetQuoted, token) {
+	switch state {
+	// We are currently still parsing whitespace
+	case VALUE_PARSE_WHITESPACE:
+		switch convertToken(token) {
+		// Encounter another whitespace
+		case Whitespace:
+			return state
+		// The string opens
+		case Quote:
+			return VALUE_PARSE_QUOTED
+		default:
+			// The string opens unquoted, check if the token is valid
+			if invalidValueTableUncommented[token] {
+				// this character is not allowed
 				return VALUE_PARSE_ERROR
 			}
+			return VALUE_PARSE_UNQUOTED
+		}
 
-		case VALUE_PARSE_QUOTED_TERMINATED:
-			// Only allow whitespace after a quoted string terminates
-			if convertToken(token) != Whitespace {
-				return VALUE_PARSE_ERROR
-			}
+	case VALUE_PARSE_QUOTED:
+		// Start escaping on a backslash, terminate on a quote
+		if token == B_BACKSLASH {
+			return VALUE_PARSE_QUOTED_BACKSLASH
+		}
+		if token == B_QUOTE {
+			return VALUE_PARSE_QUOTED_TERMINATED
+		}
+		if invalidValueTableQuoted[token] {
+			return VALUE_PARSE_ERROR
+		}
 
-		case VALUE_PARSE_QUOTED_BACKSLASH:
-			// After a backslash basically anything is allowed except invalid bytes
-			if slices.Contains(invalidValueByteSetQuoted, token) {
-				return VALUE_PARSE_ERROR
+	case VALUE_PARSE_QUOTED_TERMINATED:
+		// Only allow whitespace after a quoted string terminates
+		if convertToken(token) != Whitespace {
+			return VALUE_PARSE_ERROR
+		}
 
-			}
-			state = VALUE_PARSE_QUOTED
+	case VALUE_PARSE_QUOTED_BACKSLASH:
+		// After a backslash basically anything is allowed except invalid bytes
+		if invalidValueTableQuoted[token] {
+			return VALUE_PARSE_ERROR
+		}
+		return VALUE_PARSE_QUOTED
 
-		case VALUE_PARSE_UNQUOTED:
-			// Check for invalid bytes
-			if slices.Contains(invalidValueByteSetUnquoted, token) {
-				return VALUE_PARSE_ERROR
+	case VALUE_PARSE_TRIPLE_QUOTED, VALUE_PARSE_TRIPLE_QUOTED_1, VALUE_PARSE_TRIPLE_QUOTED_2:
+		// Anything but a null goes, three quotes in a row terminate the string
+		if token == B_NULL {
+			return VALUE_PARSE_ERROR
+		}
+		if token != B_QUOTE {
+			return VALUE_PARSE_TRIPLE_QUOTED
+		}
+		if state == VALUE_PARSE_TRIPLE_QUOTED_2 {
+			return VALUE_PARSE_QUOTED_TERMINATED
+		}
+		return state + 1
+
+	case VALUE_PARSE_UNQUOTED:
+		// Check for invalid bytes
+		if invalidValueTableUncommented[token] {
+			return VALUE_PARSE_ERROR
+		}
+	case VALUE_PARSE_EMBEDDED, VALUE_PARSE_EMBEDDED_QUOTED:
+		// Quotes open and close anywhere, backslashes escape the next byte
+		switch token {
+		case B_NULL:
+			return VALUE_PARSE_ERROR
+		case B_BACKSLASH:
+			return state + 1
+		case B_QUOTE:
+			if state == VALUE_PARSE_EMBEDDED {
+				return VALUE_PARSE_EMBEDDED_QUOTED
 			}
-		default:
-			panic("unknown state")
+			return VALUE_PARSE_EMBEDDED
 		}
 
+	case VALUE_PARSE_EMBEDDED_BACKSLASH, VALUE_PARSE_EMBEDDED_QUOTED_BACKSLASH:
+		// Anything but a null may be escaped, a carriage return is skipped
+		// as part of the newline escaped by a line continuation
+		switch token {
+		case B_NULL:
+			return VALUE_PARSE_ERROR
+		case B_CR:
+			return state
+		}
+		return state - 1
+
+	case VALUE_PARSE_RAW:
+		// Anything but a null goes
+		if token == B_NULL {
+			return VALUE_PARSE_ERROR
+		}
+
+	case VALUE_PARSE_ERROR:
+		// An error is final
+	default:
+		panic("unknown state")
 	}
-	return
+	return state
 }
 
-// isExtraQuoteLegal returns if `content` would still be legal after adding a quote
+// isExtraQuoteLegal returns if a value in `state` would still be legal after adding a quote
 //
 // This means concretely either:
 // The string is empty
 // The string has only whitespace
 // The string is a non-terminated quoted string
 // The last content character is an escaping backslash that is itself not escaped
-func isExtraQuoteLegal(content []byte) bool {
-	state := valueStringState(content)
+func isExtraQuoteLegal(state int) bool {
 	return state != VALUE_PARSE_QUOTED_TERMINATED &&
 		state != VALUE_PARSE_UNQUOTED &&
 		state != VALUE_PARSE_ERROR
@@ -0,0 +1,99 @@
+package montoya
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// generateInventory creates an ini file of roughly `size` bytes, resembling a
+// generated host inventory
+func generateInventory(size int) []byte {
+	var buf bytes.Buffer
+	for host := 0; buf.Len() < size; host++ {
+		fmt.Fprintf(&buf, "; host %d\n[host-%05d.example.com]\n", host, host)
+		fmt.Fprintf(&buf, "address = 10.%d.%d.%d\n", host>>16&0xFF, host>>8&0xFF, host&0xFF)
+		fmt.Fprintf(&buf, "  description = \"generated host \\\"%d\\\" ; do not edit\" # note\n", host)
+		fmt.Fprintf(&buf, "tags=web,db,cache,monitoring,backup,region-%d\n", host%7)
+		buf.WriteString("enabled = yes\n\n")
+	}
+	return buf.Bytes()
+}
+
+// writeBenchFile writes `content` to a file in a temporary directory
+func writeBenchFile(b *testing.B, content []byte) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "inventory.ini")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkParseFile parses a multi-megabyte file from an unbuffered *os.File
+func BenchmarkParseFile(b *testing.B) {
+	content := generateInventory(4 << 20)
+	path := writeBenchFile(b, content)
+	b.SetBytes(int64(len(content)))
+
+	for b.Loop() {
+		input, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Parse(input); err != nil {
+			b.Fatal(err)
+		}
+		input.Close()
+	}
+}
+
+// BenchmarkParseMemory parses a multi-megabyte file from memory
+func BenchmarkParseMemory(b *testing.B) {
+	content := generateInventory(4 << 20)
+	b.SetBytes(int64(len(content)))
+
+	for b.Loop() {
+		if _, err := Parse(bytes.NewReader(content)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTokenizeFile tokenizes a multi-megabyte file from an unbuffered *os.File
+func BenchmarkTokenizeFile(b *testing.B) {
+	content := generateInventory(4 << 20)
+	path := writeBenchFile(b, content)
+	b.SetBytes(int64(len(content)))
+
+	for b.Loop() {
+		input, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Tokenize(input); err != nil {
+			b.Fatal(err)
+		}
+		input.Close()
+	}
+}
+
+// BenchmarkWriteTo writes a parsed multi-megabyte file back out
+func BenchmarkWriteTo(b *testing.B) {
+	content := generateInventory(4 << 20)
+	file, err := Parse(bytes.NewReader(content))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(content)))
+
+	var out bytes.Buffer
+	for b.Loop() {
+		out.Reset()
+		if _, err := file.WriteTo(&out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
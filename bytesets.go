@@ -1,7 +1,5 @@
 package montoya
 
-import "slices"
-
 const B_NULL byte = 0x00
 const B_NEWLINE byte = 0x0A
 const B_SPACE byte = 0x20
@@ -16,6 +14,9 @@ const B_QUOTE byte = 0x22
 const B_BACKSLASH byte = 0x5C
 const B_US byte = 0x1F
 
+// utf8BOM is the UTF-8 encoded byte order mark that may precede a file
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
 var commentStartBytes = []byte{
 	B_HASH,
 	B_SEMICOLON,
@@ -40,6 +41,14 @@ var invalidSectionByteSet = []byte{
 }
 var validSectionByteSet = invertByteSet(invalidSectionByteSet)
 
+// Strict section names may only contain printable ASCII other than brackets,
+// see `Options.StrictSectionNames`
+var validStrictSectionByteSet = []byte(" !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ\\^_`abcdefghijklmnopqrstuvwxyz{|}~")
+
+// Locales may only contain ASCII letters, digits and the separators of
+// `lang_COUNTRY.ENCODING@MODIFIER`, see `Options.LocaleKeys`
+var validLocaleByteSet = []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_.@-")
+
 // Comment may not contain nulls or newlines
 var invalidCommentByteSet = []byte{
 	B_NULL,
@@ -57,6 +66,14 @@ var invalidValueByteSetUnquoted = []byte{
 }
 var validValueByteSetUnquoted = invertByteSet(invalidValueByteSetUnquoted)
 
+// Values not inside quotes may contain comment symbols where those do not
+// start an inline comment, see `Options.InlineComments`
+var invalidValueByteSetUncommented = []byte{
+	B_NULL,
+	B_NEWLINE,
+	B_QUOTE,
+}
+
 // Values inside quotes may contain comment starts and quotes, if they are escaped
 var invalidValueByteSetQuoted = []byte{
 	B_NULL,
@@ -107,12 +124,32 @@ var validKeyByteSet = invertByteSet(invalidKeyByteSet)
 
 // invertBytes returns a set of all possible byte values excluding those in `invalid`
 func invertByteSet(invalid []byte) (inverted []byte) {
+	table := newByteTable(invalid)
 	for i := range 256 {
-		b := byte(i)
-		if slices.Contains(invalid, b) {
+		if table[i] {
 			continue
 		}
-		inverted = append(inverted, b)
+		inverted = append(inverted, byte(i))
 	}
 	return
 }
+
+// byteTable is a lookup table with an entry for every possible byte value,
+// used to classify bytes in constant time
+type byteTable [256]bool
+
+// newByteTable returns a table in which exactly the bytes in `set` are true
+func newByteTable(set []byte) (table byteTable) {
+	for _, b := range set {
+		table[b] = true
+	}
+	return
+}
+
+var keyByteTable = newByteTable(validKeyByteSet)
+var sectionByteTable = newByteTable(validSectionByteSet)
+var strictSectionByteTable = newByteTable(validStrictSectionByteSet)
+var localeByteTable = newByteTable(validLocaleByteSet)
+var invalidValueTableUnquoted = newByteTable(invalidValueByteSetUnquoted)
+var invalidValueTableUncommented = newByteTable(invalidValueByteSetUncommented)
+var invalidValueTableQuoted = newByteTable(invalidValueByteSetQuoted)
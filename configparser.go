@@ -0,0 +1,32 @@
+package montoya
+
+import "strings"
+
+// ConfigParser returns the options for parsing files shared with Python's
+// configparser, as read by a default `ConfigParser()`
+//
+// Keys are separated from their values by `=` or `:` and are looked up in
+// lower case, while section names match exactly. Values are taken verbatim
+// and continue on every following indented line. Keys of the `[DEFAULT]`
+// section are fallbacks for every other section, and `%(key)s` references
+// are resolved when values are read through the file, e.g. by GetString. Set
+// `Lookup.Interpolation` to ExtendedInterpolation for `${section:key}`
+// references instead. Duplicate sections and keys are errors.
+//
+// Unlike configparser, keys cannot contain whitespace, and a blank line or a
+// comment ends a value that continues on indented lines.
+func ConfigParser() Options {
+	return Options{
+		Delimiters:           []byte("=:"),
+		RawValues:            true,
+		IndentedContinuation: true,
+		InlineComments:       InlineCommentsNever,
+		Lookup: LookupOptions{
+			DuplicateSections: RejectDuplicates,
+			DuplicateKeys:     RejectDuplicates,
+			DefaultSection:    "DEFAULT",
+			NormalizeKey:      strings.ToLower,
+			Interpolation:     BasicInterpolation,
+		},
+	}
+}
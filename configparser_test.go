@@ -0,0 +1,181 @@
+package montoya
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configParserFixture is how Python's configparser reads a file in
+// testdata/configparser, as recorded by generate.py
+type configParserFixture struct {
+	Interpolation string                        `json:"interpolation"`
+	Sections      []string                      `json:"sections"`
+	Values        map[string]map[string]*string `json:"values"`
+}
+
+// Test files are read the way Python's configparser reads them
+func TestConfigParserConformance(t *testing.T) {
+	paths, err := filepath.Glob("testdata/configparser/*.ini")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			input, err := os.ReadFile(path)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(strings.TrimSuffix(path, ".ini") + ".json")
+			require.NoError(t, err)
+			var fixture configParserFixture
+			require.NoError(t, json.Unmarshal(expected, &fixture))
+
+			options := ConfigParser()
+			if fixture.Interpolation == "extended" {
+				options.Lookup.Interpolation = ExtendedInterpolation
+			}
+			file := parseWith(t, string(input), options)
+
+			var sections []string
+			for _, section := range file.Sections() {
+				if !section.isDefault() {
+					sections = append(sections, section.Name())
+				}
+			}
+			assert.Equal(t, fixture.Sections, sections)
+
+			for section, values := range fixture.Values {
+				var keys []string
+				for _, line := range file.Section(section).keysWithDefaults() {
+					keys = append(keys, strings.ToLower(line.Name()))
+				}
+				assert.ElementsMatch(t, keys, mapKeys(values), "section: %q", section)
+
+				for key, value := range values {
+					actual, err := file.GetString(section, key)
+					if value == nil {
+						var interpolationErr *InterpolationError
+						assert.True(t, errors.As(err, &interpolationErr), "key %q in section %q: %v", key, section, err)
+						continue
+					}
+					if assert.NoError(t, err, "key %q in section %q", key, section) {
+						assert.Equal(t, *value, actual, "key %q in section %q", key, section)
+					}
+				}
+			}
+		})
+	}
+}
+
+// mapKeys returns the keys of `m` in no particular order
+func mapKeys[V any](m map[string]V) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return
+}
+
+// Test interpolation errors name the key and section and explain the reference
+func TestInterpolationErrors(t *testing.T) {
+	input := "[DEFAULT]\nref = %(missing)s\n\n[a]\nkey = %(ref)s\nloop = %(loop)s\nlone = %\n"
+	file := parseWith(t, input, ConfigParser())
+
+	_, err := file.GetString("a", "key")
+	assert.EqualError(t, err, `cannot interpolate key "ref" in section "a": missing key "missing"`)
+	_, err = file.GetString("a", "loop")
+	assert.EqualError(t, err, `cannot interpolate key "loop" in section "a": references are nested too deeply`)
+	_, err = file.GetString("a", "lone")
+	assert.EqualError(t, err, `cannot interpolate key "lone" in section "a": "%" must be followed by "%" or "("`)
+	assert.Equal(t, "fallback", file.GetStringDefault("a", "key", "fallback"))
+
+	// values read from the line are never interpolated
+	assert.Equal(t, "%(ref)s", file.Get("a", "key").Value.Value())
+
+	file.Lookup.Interpolation = NoInterpolation
+	value, err := file.GetString("a", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "%(ref)s", value)
+}
+
+// Test Decode interpolates values, and Encode compares against interpolated
+// values and escapes the values it writes
+func TestConfigParserDecode(t *testing.T) {
+	type config struct {
+		App struct {
+			LogFile string `ini:"log_file"`
+			Port    int    `ini:"port"`
+		} `ini:"app"`
+	}
+	input := "[DEFAULT]\nbase = /srv\nport = 80%(suffix)s\nsuffix = 80\n\n[app]\nLog_File: %(base)s/app.log\n"
+	file := parseWith(t, input, ConfigParser())
+
+	var c config
+	require.NoError(t, file.Decode(&c))
+	assert.Equal(t, "/srv/app.log", c.App.LogFile)
+	assert.Equal(t, 8080, c.App.Port)
+
+	require.NoError(t, file.Encode(&c))
+	assert.Equal(t, input, readAll(t, file))
+
+	// written values are escaped so they decode as they were encoded
+	c.App.LogFile = "/srv/100%.log"
+	require.NoError(t, file.Encode(&c))
+	assert.Equal(t, "/srv/100%%.log", file.Get("app", "log_file").Value.Value())
+	var decoded config
+	require.NoError(t, file.Decode(&decoded))
+	assert.Equal(t, c, decoded)
+
+	file.Lookup.Interpolation = ExtendedInterpolation
+	require.NoError(t, file.Set("app", "log_file", "${base}/%.log"))
+	assert.Equal(t, "$${base}/%.log", file.Get("app", "log_file").Value.Value())
+	value, err := file.GetString("app", "log_file")
+	require.NoError(t, err)
+	assert.Equal(t, "${base}/%.log", value)
+	require.NoError(t, file.Set("app", "added", "$5"))
+	value, err = file.GetString("app", "added")
+	require.NoError(t, err)
+	assert.Equal(t, "$5", value)
+}
+
+// Test multi-line values are written as indented continuation lines that read back the same
+func TestConfigParserEdit(t *testing.T) {
+	file := parseWith(t, "[a]\n  k = old\n  next = 1\n", ConfigParser())
+
+	require.NoError(t, file.Set("a", "k", "line1\nline2"))
+	require.NoError(t, file.Set("a", "path", `C:\dir\`))
+	require.NoError(t, file.Set("a", "list", "\none\n\ntwo"))
+	expected := "[a]\n  k = line1\n  \tline2\n  next = 1\n  path = C:\\dir\\\n  list = \n  \tone\n  \ttwo\n"
+	assert.Equal(t, expected, readAll(t, file))
+
+	file = parseWith(t, expected, ConfigParser())
+	for key, value := range map[string]string{"k": "line1\nline2", "next": "1", "path": `C:\dir\`, "list": "\none\ntwo"} {
+		actual, err := file.GetString("a", key)
+		require.NoError(t, err, "key: %q", key)
+		assert.Equal(t, value, actual, "key: %q", key)
+	}
+
+	// continuation lines that would read as a comment or end the value are rejected
+	for _, value := range []string{"x\n#y", "x\n  ;y", "x\n\ry"} {
+		assert.Error(t, file.Set("a", "k", value), "value: %q", value)
+		assert.Error(t, file.Add("a", "added", value), "value: %q", value)
+	}
+	type config struct {
+		A struct {
+			K string `ini:"k"`
+		} `ini:"a"`
+	}
+	var c config
+	c.A.K = "x\n#y"
+	assert.Error(t, file.Encode(&c))
+	assert.Equal(t, expected, readAll(t, file))
+
+	require.NoError(t, file.Set("a", "k", "x\ny #z"))
+	value, err := parseWith(t, readAll(t, file), ConfigParser()).GetString("a", "k")
+	require.NoError(t, err)
+	assert.Equal(t, "x\ny #z", value)
+}
@@ -0,0 +1,468 @@
+package montoya
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Unmarshal parses INI data and stores the result in the struct pointed to by `v`
+//
+// See IniFile.Decode for how sections and keys map to struct fields.
+func Unmarshal(data []byte, v any) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Decoder reads an INI file from an input stream and decodes it into a struct
+type Decoder struct {
+	input io.Reader
+}
+
+// NewDecoder returns a Decoder that reads from `input`
+func NewDecoder(input io.Reader) *Decoder {
+	return &Decoder{input: input}
+}
+
+// Decode parses the input and stores the result in the struct pointed to by `v`
+func (d *Decoder) Decode(v any) error {
+	file, err := Parse(d.input)
+	if err != nil {
+		return err
+	}
+	return file.Decode(v)
+}
+
+// Decode stores the values of the file in the struct pointed to by `v`
+//
+// Fields of `v` that are structs map to sections, fields of those structs map
+// to keys. Names are taken from the `ini` struct tag, or else matched
+// case-insensitively against the field name. The tag may contain options
+// after the name:
+//
+//	Port int `ini:"port,default=8080"` // used when the key or section is missing
+//	Host string `ini:"host,omitempty"` // not written by Encode when empty
+//	Skip string `ini:"-"`              // ignored
+//
+// A default must be the last option, as it may contain commas.
+//
+// Embedded structs have their fields promoted. A map of structs, or of maps,
+// collects every section not bound to another field, keyed by section name;
+// inside a section a map collects every key. A slice collects every occurrence of a
+// repeated key. Values are converted as by the typed getters, and any type
+// implementing encoding.TextUnmarshaler is supported.
+func (f *IniFile) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %T, need a non-nil pointer to a struct", v)
+	}
+	return f.decodeFile(rv.Elem())
+}
+
+// fieldInfo describes how a struct field maps to a section or key
+type fieldInfo struct {
+	// name is the section or key name from the tag
+	name string
+	// named indicates the name was set explicitly in a tag
+	named bool
+	// omitEmpty indicates the field is not encoded when it holds a zero value
+	omitEmpty bool
+	// def holds the default value, if hasDefault is set
+	def        string
+	hasDefault bool
+}
+
+// matches checks if the field maps to the section or key called `name`,
+// comparing names as normalized by `normalize`
+func (i fieldInfo) matches(name string, normalize func(string) string) bool {
+	if i.named {
+		return normalize(i.name) == normalize(name)
+	}
+	return strings.EqualFold(normalize(i.name), normalize(name))
+}
+
+// boundField is a struct field together with its mapping
+type boundField struct {
+	fieldInfo
+	value reflect.Value
+}
+
+// parseFieldTag parses the `ini` struct tag of a field
+//
+// Returns false if the field should be skipped.
+func parseFieldTag(field reflect.StructField) (info fieldInfo, ok bool) {
+	tag, hasTag := field.Tag.Lookup("ini")
+	if tag == "-" || !field.IsExported() && !field.Anonymous {
+		return info, false
+	}
+
+	info.name = field.Name
+	if !hasTag {
+		return info, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name != "" {
+		info.name = name
+		info.named = true
+	}
+	for options != "" {
+		var option string
+		if strings.HasPrefix(options, "default=") {
+			info.def, info.hasDefault = strings.TrimPrefix(options, "default="), true
+			break
+		}
+		option, options, _ = strings.Cut(options, ",")
+		if option == "omitempty" {
+			info.omitEmpty = true
+		}
+	}
+	return info, true
+}
+
+// isEmbedded checks if a field is an embedded struct without a tag name,
+// whose fields are promoted
+func isEmbedded(field reflect.StructField, info fieldInfo) bool {
+	return field.Anonymous && !info.named && indirectType(field.Type).Kind() == reflect.Struct
+}
+
+// structFields returns the mapped fields of a struct, flattening embedded structs
+func structFields(v reflect.Value) (fields []boundField) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		info, ok := parseFieldTag(field)
+		if !ok {
+			continue
+		}
+		value := v.Field(i)
+		if isEmbedded(field, info) {
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					if !value.CanSet() {
+						continue
+					}
+					value.Set(reflect.New(value.Type().Elem()))
+				}
+				value = value.Elem()
+			}
+			fields = append(fields, structFields(value)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		fields = append(fields, boundField{fieldInfo: info, value: value})
+	}
+	return
+}
+
+// indirectType returns the type pointed to by `t`, or `t` itself
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// textUnmarshalerType is the reflected type of encoding.TextUnmarshaler
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// isScalar checks if values of type `t` are decoded from a single value
+func isScalar(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch indirectType(t).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return reflect.PointerTo(indirectType(t)).Implements(textUnmarshalerType)
+	}
+	return true
+}
+
+// decodeFile decodes every section of the file into the fields of `v`
+func (f *IniFile) decodeFile(v reflect.Value) error {
+	sections := f.Sections()
+	claimed := make(map[string]bool)
+
+	var dynamic []boundField
+	for _, field := range structFields(v) {
+		t := field.value.Type()
+		if isScalar(t) {
+			// keys outside of a section are not bound
+			continue
+		}
+		if t.Kind() == reflect.Map {
+			dynamic = append(dynamic, field)
+			continue
+		}
+		if indirectType(t).Kind() != reflect.Struct {
+			return fmt.Errorf("cannot decode section into field of type %s", t)
+		}
+		section := f.fieldSection(field.fieldInfo)
+		if section == nil {
+			if err := decodeDefaults(field.name, field.value); err != nil {
+				return err
+			}
+			continue
+		}
+		claimed[f.Lookup.sectionName(section.Name())] = true
+		if err := section.decodeInto(field.value); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range dynamic {
+		for _, section := range sections {
+			if claimed[f.Lookup.sectionName(section.Name())] || section.isDefault() {
+				continue
+			}
+			claimed[f.Lookup.sectionName(section.Name())] = true
+			section = f.Section(section.Name())
+			if err := decodeMapEntry(field.value, section.Name(), section.decodeInto); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldSection returns the first section in the file the field matches, or
+// nil if there is none
+//
+// The section is looked up by its name, following the duplicate section policy.
+func (f *IniFile) fieldSection(field fieldInfo) *Section {
+	for _, section := range f.Sections() {
+		if field.matches(section.Name(), f.Lookup.sectionName) {
+			return f.Section(section.Name())
+		}
+	}
+	return nil
+}
+
+// decodeDefaults decodes the defaults of the fields of `v`, a struct bound to
+// the missing section `section`
+//
+// A pointer to a missing section is left nil.
+func decodeDefaults(section string, v reflect.Value) error {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for _, field := range structFields(v) {
+		if !isScalar(field.value.Type()) {
+			continue
+		}
+		if err := decodeDefault(section, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeInto decodes the keys of the section into `v`, which is a struct,
+// a pointer to one, or a map
+func (s *Section) decodeInto(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	keys := s.keysWithDefaults()
+	if v.Kind() == reflect.Map {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, key := range keys {
+			err := decodeMapEntry(v, key.Name(), func(entry reflect.Value) error {
+				return s.decodeValue(entry, key)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode section %q into %s", s.Name(), v.Type())
+	}
+
+	for _, field := range structFields(v) {
+		var matched []*KeyValueLine
+		for _, key := range keys {
+			if field.matches(key.Name(), s.file.Lookup.keyName) {
+				matched = append(matched, key)
+			}
+		}
+
+		if isScalar(field.value.Type()) {
+			if len(matched) == 0 {
+				if err := decodeDefault(s.Name(), field); err != nil {
+					return err
+				}
+				continue
+			}
+			key := pick(s.file.Lookup.DuplicateKeys, matched)
+			if err := s.decodeValue(field.value, key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch field.value.Kind() {
+		case reflect.Slice:
+			if len(matched) == 0 {
+				continue
+			}
+			slice := reflect.MakeSlice(field.value.Type(), len(matched), len(matched))
+			for i, key := range matched {
+				if err := s.decodeValue(slice.Index(i), key); err != nil {
+					return err
+				}
+			}
+			field.value.Set(slice)
+		case reflect.Map:
+			if err := s.decodeInto(field.value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("cannot decode key %q into field of type %s", field.name, field.value.Type())
+		}
+	}
+	return nil
+}
+
+// decodeMapEntry decodes a new entry for `key` into the map `m` using `decode`
+func decodeMapEntry(m reflect.Value, key string, decode func(reflect.Value) error) error {
+	if m.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot decode into map with key type %s", m.Type().Key())
+	}
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	entry := reflect.New(m.Type().Elem()).Elem()
+	if err := decode(entry); err != nil {
+		return err
+	}
+	m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), entry)
+	return nil
+}
+
+// decodeValue decodes the value of `line` into `v`
+func (s *Section) decodeValue(v reflect.Value, line *KeyValueLine) error {
+	value, err := s.valueOf(line)
+	if err != nil {
+		return err
+	}
+	if !line.HasValue() && isBoolType(v.Type()) {
+		// a bare key sets a boolean
+		value = "true"
+	}
+	if err := setValue(v, value); err != nil {
+		return &ValueError{
+			Section: s.Name(),
+			Key:     line.Name(),
+			Value:   value,
+			Pos:     line.valuePosition(),
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// isBoolType checks if `t` is a boolean, or a pointer to one
+func isBoolType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Bool
+}
+
+// decodeDefault decodes the default value of a field in `section`, if it has one
+func decodeDefault(section string, field boundField) error {
+	if !field.hasDefault {
+		return nil
+	}
+	if err := setValue(field.value, field.def); err != nil {
+		return &ValueError{
+			Section: section,
+			Key:     field.name,
+			Value:   field.def,
+			Err:     fmt.Errorf("invalid default: %w", err),
+		}
+	}
+	return nil
+}
+
+// durationType is the reflected type of time.Duration
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setValue converts `value` to the type of `v` and stores it
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), value)
+	}
+	if v.CanAddr() {
+		if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(value))
+		}
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		v.SetInt(int64(d))
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := parseInt64(value)
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := parseUint64(value)
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := parseFloat(value)
+		if err != nil {
+			return err
+		}
+		if v.OverflowFloat(n) {
+			return fmt.Errorf("%g overflows %s", n, v.Type())
+		}
+		v.SetFloat(n)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("cannot decode into %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(value))
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package montoya
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogging struct {
+	Level   string `ini:"level,default=info"`
+	Verbose bool
+}
+
+type testServer struct {
+	testLogging
+
+	Host    string        `ini:"host"`
+	Port    uint16        `ini:"port,default=8080"`
+	Timeout time.Duration `ini:"timeout"`
+	Ratio   *float64      `ini:"ratio"`
+	Addr    netip.Addr    `ini:"addr"`
+	Allow   []string      `ini:"allow"`
+	Ignored string        `ini:"-"`
+}
+
+type testConfig struct {
+	Server   testServer             `ini:"server"`
+	Database *struct{ Name string } `ini:"database"`
+	Missing  *struct{ Name string } `ini:"missing"`
+	Labels   struct {
+		All map[string]string
+	} `ini:"labels"`
+	Plugins map[string]map[string]string
+}
+
+const decodeExample = `[server]
+host = example.com
+timeout = 1m30s
+ratio = 0.5
+addr = 192.0.2.1
+allow = 10.0.0.1
+allow = 10.0.0.2
+verbose = yes
+ignored = value
+
+[database]
+NAME = "main db"
+
+[labels]
+team = core
+tier = 1
+
+[plugin-a]
+enabled = true
+
+[plugin-b]
+`
+
+// Test decoding sections into nested structs
+func TestUnmarshal(t *testing.T) {
+	var config testConfig
+	require.NoError(t, Unmarshal([]byte(decodeExample), &config))
+
+	server := config.Server
+	assert.Equal(t, "example.com", server.Host)
+	assert.Equal(t, uint16(8080), server.Port)
+	assert.Equal(t, 90*time.Second, server.Timeout)
+	require.NotNil(t, server.Ratio)
+	assert.Equal(t, 0.5, *server.Ratio)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), server.Addr)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, server.Allow)
+	assert.Equal(t, "", server.Ignored)
+
+	// promoted from the embedded struct
+	assert.Equal(t, "info", server.Level)
+	assert.True(t, server.Verbose)
+
+	require.NotNil(t, config.Database)
+	assert.Equal(t, "main db", config.Database.Name)
+	assert.Nil(t, config.Missing)
+
+	assert.Equal(t, map[string]string{"team": "core", "tier": "1"}, config.Labels.All)
+	assert.Equal(t, map[string]map[string]string{
+		"plugin-a": {"enabled": "true"},
+		"plugin-b": {},
+	}, config.Plugins)
+}
+
+// Test the Decoder reads from a stream
+func TestDecoder(t *testing.T) {
+	var config testConfig
+	err := NewDecoder(bytesReader(decodeExample)).Decode(&config)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", config.Server.Host)
+}
+
+// Test decode errors report the position of the bad value
+func TestUnmarshalErrors(t *testing.T) {
+	var config testConfig
+	err := Unmarshal([]byte("[server]\nport = 70000\n"), &config)
+
+	var valueErr *ValueError
+	require.True(t, errors.As(err, &valueErr))
+	assert.Equal(t, "port", valueErr.Key)
+	assert.Equal(t, Position{Line: 2, Column: 7, Offset: 15}, valueErr.Pos)
+
+	err = Unmarshal([]byte("[server]\naddr = nope\n"), &config)
+	assert.ErrorContains(t, err, "(line:2, col:7)")
+
+	err = Unmarshal([]byte("[server\n"), &config)
+	assert.Error(t, err)
+
+	assert.Error(t, Unmarshal([]byte(""), config))
+	assert.Error(t, Unmarshal([]byte(""), new(int)))
+}
+
+// Test an invalid default is reported
+func TestUnmarshalInvalidDefault(t *testing.T) {
+	var config struct {
+		S struct {
+			N int `ini:"n,default=many"`
+		}
+	}
+	err := Unmarshal([]byte("[s]\n"), &config)
+	assert.ErrorContains(t, err, "invalid default")
+}
+
+// Test defaults apply to the fields of a missing section
+func TestUnmarshalMissingSectionDefaults(t *testing.T) {
+	var config struct {
+		Server struct {
+			Host string `ini:"host,default=localhost"`
+			Port int    `ini:"port,default=8080"`
+			Name string
+		} `ini:"server"`
+		Database *struct {
+			Port int `ini:"port,default=5432"`
+		} `ini:"database"`
+	}
+	require.NoError(t, Unmarshal([]byte("[other]\n"), &config))
+	assert.Equal(t, "localhost", config.Server.Host)
+	assert.Equal(t, 8080, config.Server.Port)
+	assert.Empty(t, config.Server.Name)
+	assert.Nil(t, config.Database)
+}
+
+// Test decoding follows the duplicate policies of the file
+func TestDecodeDuplicates(t *testing.T) {
+	options := Options{Lookup: LookupOptions{DuplicateSections: MergeDuplicates, DuplicateKeys: LastWins}}
+	file, _, err := ParseWithOptions(bytesReader("[server]\nhost = a\nallow = x\n[server]\nhost = b\nallow = y\n"), options)
+	require.NoError(t, err)
+
+	var config testConfig
+	require.NoError(t, file.Decode(&config))
+	assert.Equal(t, "b", config.Server.Host)
+	assert.Equal(t, []string{"x", "y"}, config.Server.Allow)
+}
+
+// bytesReader returns a reader over `input`
+func bytesReader(input string) *bytes.Reader {
+	return bytes.NewReader([]byte(input))
+}
+
+// Test decoding falls back to the default section, and encoding does not copy defaults
+func TestDecodeDefaultSection(t *testing.T) {
+	type section struct {
+		Host string `ini:"host"`
+		Port int    `ini:"port"`
+	}
+	type config struct {
+		Web   section                      `ini:"web"`
+		Other map[string]map[string]string `ini:"other"`
+	}
+
+	input := "[DEFAULT]\nhost = localhost\nport = 80\n\n[web]\nport = 8080\n\n[db]\n"
+	options := Options{Lookup: LookupOptions{DefaultSection: "DEFAULT"}}
+	file, _, err := ParseWithOptions(bytesReader(input), options)
+	require.NoError(t, err)
+
+	var c config
+	require.NoError(t, file.Decode(&c))
+	assert.Equal(t, section{Host: "localhost", Port: 8080}, c.Web)
+	assert.Equal(t, map[string]map[string]string{"db": {"host": "localhost", "port": "80"}}, c.Other)
+
+	require.NoError(t, file.Encode(&c))
+	assert.Equal(t, input, readAll(t, file))
+}
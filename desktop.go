@@ -0,0 +1,142 @@
+package montoya
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Desktop returns the options for parsing freedesktop.org desktop entries,
+// such as `.desktop` and `.directory` files
+//
+// Keys may be localized, as in `Name[de_DE]`, and group names may only hold
+// printable ASCII other than brackets. Values are taken verbatim: comments
+// only start at the start of a line, and escapes such as `\s` and `\;` are
+// decoded by GetDesktopString, GetDesktopStringList and LocalizedString.
+// Duplicate groups and keys are errors.
+func Desktop() Options {
+	return Options{
+		StrictSectionNames: true,
+		LocaleKeys:         true,
+		RawValues:          true,
+		InlineComments:     InlineCommentsNever,
+		Lookup: LookupOptions{
+			DuplicateSections: RejectDuplicates,
+			DuplicateKeys:     RejectDuplicates,
+		},
+	}
+}
+
+// LocalizedString returns the value of `key` in the section for `locale`,
+// such as `de_DE.UTF-8@euro`, with escapes decoded
+//
+// Like the desktop entry specification, the encoding of the locale is ignored
+// and the key is looked up for `lang_COUNTRY@MODIFIER`, `lang_COUNTRY`,
+// `lang@MODIFIER` and `lang`, as far as the locale has those parts, and
+// finally without a locale.
+func (s *Section) LocalizedString(key, locale string) (string, error) {
+	for _, candidate := range localeCandidates(locale) {
+		if line := s.Key(key + "[" + candidate + "]"); line != nil {
+			value, err := s.valueOf(line)
+			return unescapeDesktop(value), err
+		}
+	}
+	line := s.Key(key)
+	if line == nil {
+		return "", fmt.Errorf("%w: %q in section %q", ErrKeyNotFound, key, s.Name())
+	}
+	value, err := s.valueOf(line)
+	return unescapeDesktop(value), err
+}
+
+// GetDesktopString returns the value of `key` in `section` with the escapes
+// of a desktop entry decoded: `\s`, `\n`, `\t`, `\r` and `\\`
+func (f *IniFile) GetDesktopString(section, key string) (string, error) {
+	return getTyped(f, section, key, func(value string) (string, error) {
+		return unescapeDesktop(value), nil
+	})
+}
+
+// GetDesktopStringList returns the value of `key` in `section` as a desktop
+// entry list, whose elements are separated by `;`
+//
+// An escaped `\;` is part of an element, and the other escapes are decoded as
+// GetDesktopString does. The `;` after the last element is optional.
+func (f *IniFile) GetDesktopStringList(section, key string) ([]string, error) {
+	return getTyped(f, section, key, func(value string) ([]string, error) {
+		return splitDesktopList(value), nil
+	})
+}
+
+// localeCandidates returns the locales a localized key is looked up for, in
+// order, see LocalizedString
+func localeCandidates(locale string) (candidates []string) {
+	locale, modifier, hasModifier := strings.Cut(locale, "@")
+	locale, _, _ = strings.Cut(locale, ".")
+	lang, country, hasCountry := strings.Cut(locale, "_")
+	if lang == "" {
+		return nil
+	}
+	if hasCountry && hasModifier {
+		candidates = append(candidates, lang+"_"+country+"@"+modifier)
+	}
+	if hasCountry {
+		candidates = append(candidates, lang+"_"+country)
+	}
+	if hasModifier {
+		candidates = append(candidates, lang+"@"+modifier)
+	}
+	return append(candidates, lang)
+}
+
+// desktopEscapes maps the byte after a backslash in a desktop entry value to
+// the byte it stands for
+var desktopEscapes = map[byte]byte{
+	's':         B_SPACE,
+	'n':         B_NEWLINE,
+	't':         B_TAB,
+	'r':         B_CR,
+	B_BACKSLASH: B_BACKSLASH,
+}
+
+// unescapeDesktop decodes the escapes in a desktop entry value, keeping
+// unknown escapes as they are
+func unescapeDesktop(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == B_BACKSLASH && i+1 < len(value) {
+			if b, ok := desktopEscapes[value[i+1]]; ok {
+				out.WriteByte(b)
+				i++
+				continue
+			}
+		}
+		out.WriteByte(value[i])
+	}
+	return out.String()
+}
+
+// splitDesktopList splits a desktop entry value on unescaped `;` and decodes
+// the escapes in every element
+func splitDesktopList(value string) (elements []string) {
+	var element strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == B_BACKSLASH && i+1 < len(value) && value[i+1] == B_SEMICOLON:
+			element.WriteByte(B_SEMICOLON)
+			i++
+		case value[i] == B_BACKSLASH && i+1 < len(value):
+			// decoded by unescapeDesktop, but skipped so `\\;` ends the element
+			element.WriteString(value[i : i+2])
+			i++
+		case value[i] == B_SEMICOLON:
+			elements = append(elements, unescapeDesktop(element.String()))
+			element.Reset()
+		default:
+			element.WriteByte(value[i])
+		}
+	}
+	if element.Len() > 0 {
+		elements = append(elements, unescapeDesktop(element.String()))
+	}
+	return
+}
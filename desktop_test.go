@@ -0,0 +1,165 @@
+package montoya
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const desktopExample = `[Desktop Entry]
+Type=Application
+Name=Text Editor
+Name[de]=Texteditor
+Name[sr@latin]=Uređivač teksta
+Name[pt_BR]=Editor de texto
+Comment = Edit text files # not a comment
+Comment[fr] = Modifier des fichiers\stexte
+Exec=editor %F
+Categories=GTK;Utility;TextEditor;
+Keywords[de]=Text;Editor\;Notizen;
+MimeType=text/plain;text/x-c\\;
+
+[Desktop Action new-window]
+Name=New Window
+`
+
+// Test a desktop entry round trips and localized keys parse into their own node
+func TestDesktop(t *testing.T) {
+	file := parseWith(t, desktopExample, Desktop())
+
+	line := file.Get("Desktop Entry", "Name[pt_BR]")
+	require.NotNil(t, line)
+	assert.Equal(t, "Name", string(line.Key.Content()))
+	assert.Equal(t, "pt_BR", line.Locale.Value())
+	assert.Equal(t, span(6, 5, 107, 12, 114), line.Locale.Span())
+	assert.Equal(t, "Text Editor", file.Get("Desktop Entry", "Name").Value.Value())
+	assert.Nil(t, file.Get("Desktop Entry", "Name").Locale)
+	assert.Nil(t, file.Get("Desktop Entry", "Name[en]"))
+
+	comment, err := file.GetDesktopString("Desktop Entry", "Comment[fr]")
+	require.NoError(t, err)
+	assert.Equal(t, "Modifier des fichiers texte", comment)
+	assert.Equal(t, "Edit text files # not a comment", file.Get("Desktop Entry", "Comment").Value.Value())
+
+	categories, err := file.GetDesktopStringList("Desktop Entry", "Categories")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GTK", "Utility", "TextEditor"}, categories)
+	keywords, err := file.GetDesktopStringList("Desktop Entry", "Keywords[de]")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Text", "Editor;Notizen"}, keywords)
+	mimeTypes, err := file.GetDesktopStringList("Desktop Entry", "MimeType")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"text/plain", `text/x-c\`}, mimeTypes)
+
+	assert.Equal(t, "New Window", file.Get("Desktop Action new-window", "Name").Value.Value())
+
+	// comment symbols are valid in group names
+	file = parseWith(t, "[Group#1;a] # comment\nName=x\n", Desktop())
+	assert.Equal(t, "x", file.Get("Group#1;a", "Name").Value.Value())
+	assert.Equal(t, " comment", string(file.Section("Group#1;a").Header.Comment.Content()))
+}
+
+// Test localized strings fall back in the order of the specification
+func TestLocalizedString(t *testing.T) {
+	file := parseWith(t, desktopExample, Desktop())
+	section := file.Section("Desktop Entry")
+
+	cases := map[string]string{
+		"de":               "Texteditor",
+		"de_AT":            "Texteditor",
+		"de_DE.UTF-8@euro": "Texteditor",
+		"sr@latin":         "Uređivač teksta",
+		"sr_RS@latin":      "Uređivač teksta",
+		"sr_RS":            "Text Editor",
+		"pt_BR":            "Editor de texto",
+		"pt":               "Text Editor",
+		"":                 "Text Editor",
+	}
+	for locale, expected := range cases {
+		value, err := section.LocalizedString("Name", locale)
+		require.NoError(t, err, "locale: %q", locale)
+		assert.Equal(t, expected, value, "locale: %q", locale)
+	}
+
+	comment, err := section.LocalizedString("Comment", "fr_FR")
+	require.NoError(t, err)
+	assert.Equal(t, "Modifier des fichiers texte", comment)
+
+	_, err = section.LocalizedString("GenericName", "de")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.Equal(t, []string{"de_DE@euro", "de_DE", "de@euro", "de"}, localeCandidates("de_DE.UTF-8@euro"))
+	assert.Equal(t, []string{"de"}, localeCandidates("de.UTF-8"))
+}
+
+// Test group names and locales are validated
+func TestDesktopInvalid(t *testing.T) {
+	cases := []struct {
+		input string
+		code  ErrorCode
+	}{
+		{"[Desktop Entrée]\n", ErrInvalidSectionName},
+		{"[Desktop [Entry]\n", ErrInvalidSectionName},
+		{"[Desktop\tEntry]\n", ErrInvalidSectionName},
+		{"[a]\nName[]=x\n", ErrInvalidLocale},
+		{"[a]\nName[de DE]=x\n", ErrInvalidLocale},
+		{"[a]\nName[de]x=y\n", ErrInvalidLocale},
+		{"[a]\nName[de\n", ErrInvalidLocale},
+		{"[a]\nName=x\nName=y\n", ErrDuplicateKey},
+	}
+	for _, c := range cases {
+		_, _, err := ParseWithOptions(bytesReader(c.input), Desktop())
+		var parseErr *ParseError
+		require.True(t, errors.As(err, &parseErr), "input: %q, err: %v", c.input, err)
+		assert.Equal(t, c.code, parseErr.Code, "input: %q", c.input)
+	}
+
+	// without localized keys the brackets are invalid in a key
+	_, _, err := ParseWithOptions(bytesReader("Name[de]=x\n"), Options{})
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, ErrInvalidKey, parseErr.Code)
+}
+
+// Test editing a desktop entry adds localized keys and checks group names
+func TestDesktopEdit(t *testing.T) {
+	file := parseWith(t, "[Desktop Entry]\nName=Editor\nName[de]=Texteditor\n", Desktop())
+
+	require.NoError(t, file.Set("Desktop Entry", "Name[fr]", "Éditeur"))
+	require.NoError(t, file.Set("Desktop Entry", "Name[de]", "Editor"))
+	line := file.Get("Desktop Entry", "Name[fr]")
+	require.NotNil(t, line)
+	assert.Equal(t, "fr", line.Locale.Value())
+	assert.Equal(t, "[Desktop Entry]\nName=Editor\nName[de]=Editor\nName[fr]=Éditeur\n", readAll(t, file))
+
+	assert.Error(t, file.Set("Desktop Entry", "Name[fr", "x"))
+	assert.Error(t, file.Set("Desktop Entry", "Name[]", "x"))
+	_, err := file.AddSection("Desktop Entrée")
+	assert.Error(t, err)
+	_, err = file.AddSection("Desktop Action edit")
+	assert.NoError(t, err)
+}
+
+// Test newlines in values are escaped instead of starting a new line, and
+// trailing backslashes are kept as they are
+func TestDesktopEditRoundTrip(t *testing.T) {
+	file := parseWith(t, "[Desktop Entry]\nName=Editor\nPath=/srv\n", Desktop())
+
+	file.Get("Desktop Entry", "Name").SetValue("x\nExec=evil")
+	require.NoError(t, file.Set("Desktop Entry", "Path", `C:\dir\`))
+	require.NoError(t, file.Set("Desktop Entry", "Comment", "a\r\nb"))
+	expected := "[Desktop Entry]\nName=x\\nExec=evil\nPath=C:\\dir\\\nComment=a\\r\\nb\n"
+	assert.Equal(t, expected, readAll(t, file))
+
+	file = parseWith(t, expected, Desktop())
+	assert.Nil(t, file.Get("Desktop Entry", "Exec"))
+	name, err := file.GetDesktopString("Desktop Entry", "Name")
+	require.NoError(t, err)
+	assert.Equal(t, "x\nExec=evil", name)
+	comment, err := file.GetDesktopString("Desktop Entry", "Comment")
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\nb", comment)
+	assert.Equal(t, `C:\dir\`, file.Get("Desktop Entry", "Path").Value.Value())
+}
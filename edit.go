@@ -0,0 +1,258 @@
+package montoya
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrSectionNotFound is returned when editing a section that does not exist
+var ErrSectionNotFound = errors.New("section not found")
+
+// SetValue replaces the value of the line
+//
+// Only the value itself is replaced; the whitespace around it, the key and any
+// trailing comment are left untouched. The value is quoted and escaped as
+// needed by EncodeValue, or as the syntax of the value requires, see
+// `Options.EmbeddedQuotes` and `Options.RawValues`.
+func (l *KeyValueLine) SetValue(value string) {
+	if l.Value == nil {
+		// a bare key gains a value, followed by the whitespace after the key
+		node := &ValueNode{syntax: l.syntax}
+		node.content = append(node.encode(value, l.Padding.Content()), l.PostKeyPad.Content()...)
+		l.Value = node
+		l.PostKeyPad = nil
+		l.Reset()
+		return
+	}
+	leading, _, trailing := splitValuePadding(l.Value.content)
+
+	content := append([]byte{}, leading...)
+	content = append(content, l.Value.encode(value, l.Padding.Content())...)
+	content = append(content, trailing...)
+	l.Value.content = content
+	l.Reset()
+}
+
+// splitValuePadding splits raw value content into leading whitespace, the
+// value and trailing whitespace
+//
+// A value consisting only of whitespace is considered leading whitespace,
+// except for a trailing carriage return which is kept at the end of the line.
+func splitValuePadding(content []byte) (leading, value, trailing []byte) {
+	whitespace := string(validWhitespaceByteSet)
+	trimmed := bytes.TrimLeft(content, whitespace)
+	if len(trimmed) == 0 {
+		if n := len(content); n > 0 && content[n-1] == B_CR {
+			return content[:n-1], nil, content[n-1:]
+		}
+		return content, nil, nil
+	}
+	leading = content[:len(content)-len(trimmed)]
+	value = bytes.TrimRight(trimmed, whitespace)
+	trailing = trimmed[len(value):]
+	return
+}
+
+// Set sets the value of `key` in `section`
+//
+// An existing key has its value replaced by SetValue. A missing key is
+// inserted after the last key in the section, copying the formatting of its
+// siblings. A key that is only found in the default section is added to the
+// section, overriding the default. An empty section name sets a key in the
+// global section, in front of the first header.
+//
+// With `Lookup.Interpolation` set, the symbols that would start a reference
+// are escaped, so GetString returns the value as it was set.
+//
+// A value that would not read back the same is an error, such as a line of a
+// multi-line value that starts with a comment symbol while values continue
+// on indented lines.
+func (f *IniFile) Set(section, key, value string) error {
+	s := f.Section(section)
+	if s == nil {
+		return fmt.Errorf("%w: %q", ErrSectionNotFound, section)
+	}
+	if !f.isValidValue(value) {
+		return fmt.Errorf("invalid value %q", value)
+	}
+	value = f.Lookup.escapeReferences(value)
+	if lines := s.ownKeys(key); len(lines) > 0 {
+		pick(f.Lookup.DuplicateKeys, lines).SetValue(value)
+		return nil
+	}
+	return f.addKey(s, key, value)
+}
+
+// Add adds `key` with `value` to `section`, even if the key exists already,
+// like `git config --add` does
+//
+// The new line is inserted after the last occurrence of the key, or where Set
+// would insert a missing key.
+func (f *IniFile) Add(section, key, value string) error {
+	s := f.Section(section)
+	if s == nil {
+		return fmt.Errorf("%w: %q", ErrSectionNotFound, section)
+	}
+	if !f.isValidValue(value) {
+		return fmt.Errorf("invalid value %q", value)
+	}
+	return f.addKey(s, key, value)
+}
+
+// addKey inserts a new KeyValueLine into the section, after the last
+// occurrence of the key or else after the last key in the section
+func (f *IniFile) addKey(s *Section, key, value string) error {
+	if !f.isValidKey(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+
+	keys := s.ownKeys(key)
+	if len(keys) == 0 {
+		keys = s.Keys()
+	}
+	template := f.firstKey()
+	if len(keys) > 0 {
+		template = keys[len(keys)-1]
+	}
+	line := newKeyValueLine(key, value, template)
+	f.setKeyName(line, key)
+	if template == nil {
+		line.delimiter = f.options.delimiters()[0]
+	}
+	if syntax := f.options.valueSyntax(); line.Value.syntax != syntax {
+		line.Value.syntax = syntax
+		line.SetValue(value)
+	}
+
+	switch {
+	case len(keys) > 0:
+		f.InsertAfter(template, line)
+	case s.Header == nil:
+		f.InsertBefore(f.firstSectionStart(), line)
+	default:
+		f.InsertAfter(s.Header, line)
+	}
+	return nil
+}
+
+// firstSectionStart returns the first line of the first section, including
+// the comment block above its header, or nil if the file has no sections
+func (f *IniFile) firstSectionStart() IniLine {
+	sections := f.Sections()
+	if len(sections) == 0 {
+		return nil
+	}
+	return f.headerExtent(sections[0].Header)[0]
+}
+
+// isValidKey checks if `key` can be written as the key of a KeyValueLine in
+// the file
+//
+// With localized keys, a key may end in a locale in brackets, as in
+// `Name[de_DE]`.
+func (f *IniFile) isValidKey(key string) bool {
+	name, locale, found := strings.Cut(key, "[")
+	if !f.options.LocaleKeys || !found {
+		return isValidKey(key, f.options.delimiters())
+	}
+	locale, closed := strings.CutSuffix(locale, "]")
+	return closed && isValidKey(name, f.options.delimiters()) && isValidLocale(locale)
+}
+
+// isValidValue checks if `value` can be written as the value of a
+// KeyValueLine in the file and read back
+//
+// A value continued on indented lines cannot hold a line that would be read
+// as a comment or as the end of the value, see encodeIndented.
+func (f *IniFile) isValidValue(value string) bool {
+	return f.options.valueSyntax() != rawIndentedValue || isIndentable(value)
+}
+
+// setKeyName sets the name of the key, splitting off a locale in brackets if
+// the file has localized keys
+func (f *IniFile) setKeyName(line *KeyValueLine, key string) {
+	name, locale, found := strings.Cut(key, "[")
+	if !f.options.LocaleKeys || !found {
+		line.Key.content = []byte(key)
+		line.Locale = nil
+		return
+	}
+	line.Key.content = []byte(name)
+	line.Locale = &LocaleNode{content: []byte("[" + locale)}
+}
+
+// isValidLocale checks if `locale` can be written in between the brackets
+// after a key, see `Options.LocaleKeys`
+func isValidLocale(locale string) bool {
+	if locale == "" {
+		return false
+	}
+	for i := 0; i < len(locale); i++ {
+		if !isLocaleByte(locale[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidKey checks if `key` can be written as the key of a KeyValueLine, in
+// a file separating keys from values by `delimiters`
+func isValidKey(key string, delimiters []byte) bool {
+	if key == "" || convertToken(key[0]) == CommentStart {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isKeyByte(key[i]) || slices.Contains(delimiters, key[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// firstKey returns the first KeyValueLine in the file, or nil if there is none
+func (f *IniFile) firstKey() *KeyValueLine {
+	for line := f.Head; line != nil; line = line.Next() {
+		if key, ok := line.(*KeyValueLine); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// newKeyValueLine creates a KeyValueLine that copies the indentation, the
+// delimiter and the whitespace around it of `template`
+//
+// Without a template the line is formatted as `key = value`. The line is not
+// terminated; that is left to the insertion into a file.
+func newKeyValueLine(key, value string, template *KeyValueLine) *KeyValueLine {
+	padding, postKeyPad, valuePad := []byte{}, []byte{B_SPACE}, []byte{B_SPACE}
+	syntax := standardValue
+	if template != nil {
+		padding = bytes.Clone(template.Padding.Content())
+	}
+	// a bare key has no whitespace around a delimiter to copy
+	if template != nil && template.HasValue() {
+		postKeyPad = bytes.Clone(template.PostKeyPad.Content())
+		valuePad, _, _ = splitValuePadding(template.Value.Content())
+		valuePad = bytes.TrimRight(valuePad, string(B_CR))
+		syntax = template.Value.syntax
+	}
+
+	node := &ValueNode{syntax: syntax}
+	node.content = append(bytes.Clone(valuePad), node.encode(value, padding)...)
+	line := &KeyValueLine{
+		Padding: &WhitespaceNode{content: padding},
+		Key:     &KeyNode{content: []byte(key)},
+		Value:   node,
+	}
+	if template != nil {
+		line.delimiter = template.delimiter
+	}
+	if len(postKeyPad) > 0 {
+		line.PostKeyPad = &WhitespaceNode{content: postKeyPad}
+	}
+	return line
+}
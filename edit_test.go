@@ -0,0 +1,145 @@
+package montoya
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads a file back to a string
+func readAll(t *testing.T, file *IniFile) string {
+	t.Helper()
+	file.Reset()
+	output, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return string(output)
+}
+
+// Test SetValue only replaces the value, leaving formatting intact
+func TestSetValuePreservesFormatting(t *testing.T) {
+	cases := []struct {
+		input, value, expected string
+	}{
+		{"  key\t =  old  ; comment\n", "new", "  key\t =  new  ; comment\n"},
+		{"key=old\n", "new", "key=new\n"},
+		{"key = old\r\n", "new", "key = new\r\n"},
+		{"key = \"old\"\n", "new", "key = new\n"},
+		{"key =\n", "new", "key =new\n"},
+		{"key = \r\n", "new", "key = new\r\n"},
+		{"key = old # comment\n", "a # b", "key = \"a # b\" # comment\n"},
+		{"key = old", " padded ", "key = \" padded \""},
+	}
+
+	for _, c := range cases {
+		file, err := testParse(c.input)
+		require.NoError(t, err)
+
+		line := file.Head.(*KeyValueLine)
+		line.SetValue(c.value)
+
+		assert.Equal(t, c.expected, readAll(t, file))
+		assert.Equal(t, c.value, line.Value.Value())
+	}
+}
+
+// Test Set replaces existing values in the right section
+func TestSetExistingKey(t *testing.T) {
+	file, err := testParse("[a]\nkey = 1\n[b]\nkey = 2 ; two\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("b", "key", "3"))
+	assert.Equal(t, "[a]\nkey = 1\n[b]\nkey = 3 ; two\n", readAll(t, file))
+}
+
+// Test Set inserts missing keys after the last key, copying the sibling style
+func TestSetInsertsKey(t *testing.T) {
+	file, err := testParse("[a]\n\tfirst  =  1\n\tsecond  =  2 ; comment\n\n[b]\nx=1\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("a", "third", "3"))
+	assert.Equal(t, "[a]\n\tfirst  =  1\n\tsecond  =  2 ; comment\n\tthird  =  3\n\n[b]\nx=1\n", readAll(t, file))
+	assert.Equal(t, "3", file.Get("a", "third").Value.Value())
+}
+
+// Test Set inserts into an empty section after the header, copying the style of other sections
+func TestSetInsertsIntoEmptySection(t *testing.T) {
+	file, err := testParse("[a]\nkey: = 1\n[b] ; empty\n[c]\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("b", "new", "value"))
+	assert.Equal(t, "[a]\nkey: = 1\n[b] ; empty\nnew = value\n[c]\n", readAll(t, file))
+}
+
+// Test Set keeps CRLF line endings and a missing final newline
+func TestSetInsertsAtEndOfFile(t *testing.T) {
+	file, err := testParse("[a]\r\nkey=1")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("a", "other", "2"))
+	assert.Equal(t, "[a]\r\nkey=1\r\nother=2", readAll(t, file))
+	assert.Equal(t, "2", file.Get("a", "other").Value.Value())
+	assert.Same(t, IniLine(file.Get("a", "other")), file.Tail)
+
+	file, err = testParse("[a]\r\nkey=1\r\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("a", "other", "2"))
+	assert.Equal(t, "[a]\r\nkey=1\r\nother=2\r\n", readAll(t, file))
+	assert.Equal(t, "2", file.Get("a", "other").Value.Value())
+}
+
+// Test the result of Set can be parsed again to the same file
+func TestSetRoundTrips(t *testing.T) {
+	file, err := testParse("[a]\n  key = 1 ; c\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("a", "key", "multi\nline; \"quoted\""))
+	require.NoError(t, file.Set("a", "other", "# hash"))
+	output := readAll(t, file)
+
+	reparsed, err := Parse(bytes.NewReader([]byte(output)))
+	require.NoError(t, err)
+	assert.Equal(t, "multi\nline; \"quoted\"", reparsed.Get("a", "key").Value.Value())
+	assert.Equal(t, "# hash", reparsed.Get("a", "other").Value.Value())
+}
+
+// Test Set errors on missing sections and invalid keys
+func TestSetErrors(t *testing.T) {
+	file, err := testParse("[a]\n")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, file.Set("missing", "key", "value"), ErrSectionNotFound)
+	assert.Error(t, file.Set("a", "", "value"))
+	assert.Error(t, file.Set("a", "bad key", "value"))
+	assert.Error(t, file.Set("a", "#comment", "value"))
+	assert.Equal(t, "[a]\n", readAll(t, file))
+}
+
+// Test Set adds global keys in front of the first header and its comments
+func TestSetGlobal(t *testing.T) {
+	file, err := testParse("; file comment\n\n; about a\n[a]\nkey = 1\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("", "first", "1"))
+	require.NoError(t, file.Set("", "second", "2"))
+	assert.Equal(t, "; file comment\n\nfirst = 1\nsecond = 2\n; about a\n[a]\nkey = 1\n", readAll(t, file))
+	assert.Equal(t, "2", file.Get("", "second").Value.Value())
+
+	file, err = testParse("global=1")
+	require.NoError(t, err)
+	require.NoError(t, file.Set("", "other", "2"))
+	assert.Equal(t, "global=1\nother=2", readAll(t, file))
+}
+
+// Test Set overrides a default in the section itself
+func TestSetOverridesDefault(t *testing.T) {
+	options := Options{Lookup: LookupOptions{DefaultSection: "DEFAULT"}}
+	file, _, err := ParseWithOptions(bytesReader("[DEFAULT]\nkey = 1\n[a]\n"), options)
+	require.NoError(t, err)
+
+	require.NoError(t, file.Set("a", "key", "2"))
+	assert.Equal(t, "[DEFAULT]\nkey = 1\n[a]\nkey = 2\n", readAll(t, file))
+}
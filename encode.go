@@ -0,0 +1,308 @@
+package montoya
+
+import (
+	"cmp"
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Encode merges the values of the struct pointed to by `v` into the file
+//
+// Fields map to sections and keys as described for Decode. Only values that
+// differ from the file are rewritten, leaving comments, ordering and
+// whitespace intact. Missing keys and sections are appended in the style of
+// the file, unless the field holds what Decode stores for a missing key: its
+// default, or else the zero value. Fields tagged `omitempty` are not added
+// while they hold a zero value either. Keys in the file without a matching
+// field are left alone, but surplus occurrences of a repeated key bound to a
+// slice are removed.
+func (f *IniFile) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot encode %T, need a struct or a pointer to one", v)
+	}
+	return f.encodeFile(rv)
+}
+
+// encodeFile encodes every section field of `v` into the file
+func (f *IniFile) encodeFile(v reflect.Value) error {
+	for _, field := range structFields(v) {
+		t := field.value.Type()
+		if isScalar(t) {
+			// keys outside of a section are not bound
+			continue
+		}
+		if t.Kind() == reflect.Map {
+			if err := encodeMap(field.value, f.encodeSection); err != nil {
+				return err
+			}
+			continue
+		}
+		// an untagged field matches its section regardless of case, as in Decode
+		name := field.name
+		if section := f.fieldSection(field.fieldInfo); section != nil {
+			name = section.Name()
+		}
+		if err := f.encodeSection(name, field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeMap calls `encode` for every entry of the map `m`, sorted by key
+func encodeMap(m reflect.Value, encode func(string, reflect.Value) error) error {
+	if m.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot encode map with key type %s", m.Type().Key())
+	}
+	keys := m.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	for _, key := range keys {
+		if err := encode(key.String(), m.MapIndex(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectionEncoder encodes fields into a section that is only added to the
+// file once a key needs to be written
+type sectionEncoder struct {
+	file    *IniFile
+	name    string
+	section *Section
+}
+
+// keys returns the keys of the section, if it exists
+func (e *sectionEncoder) keys() []*KeyValueLine {
+	if e.section == nil {
+		return nil
+	}
+	return e.section.Keys()
+}
+
+// ensure returns the section, adding it to the file if needed
+func (e *sectionEncoder) ensure() (*Section, error) {
+	if e.section != nil {
+		return e.section, nil
+	}
+	section, err := e.file.AddSection(e.name)
+	e.section = section
+	return section, err
+}
+
+// encodeSection encodes a struct or map into the section called `name`
+func (f *IniFile) encodeSection(name string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	encoder := &sectionEncoder{file: f, name: name, section: f.Section(name)}
+	return encoder.encodeFields(v)
+}
+
+// encodeFields encodes the fields of a struct, or the entries of a map, as keys
+func (e *sectionEncoder) encodeFields(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Map {
+		return encodeMap(v, func(key string, value reflect.Value) error {
+			return e.encodeKey(boundField{fieldInfo: fieldInfo{name: key, named: true}, value: value})
+		})
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot encode section %q from %s", e.name, v.Type())
+	}
+
+	for _, field := range structFields(v) {
+		if field.value.Kind() == reflect.Map && !isScalar(field.value.Type()) {
+			if err := e.encodeFields(field.value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.encodeKey(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeKey encodes a field into the matching key or keys of the section
+func (e *sectionEncoder) encodeKey(field boundField) error {
+	var matched []*KeyValueLine
+	for _, key := range e.keys() {
+		if field.matches(key.Name(), e.file.Lookup.keyName) {
+			matched = append(matched, key)
+		}
+	}
+
+	values := []reflect.Value{field.value}
+	if !isScalar(field.value.Type()) {
+		if field.value.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot encode key %q from %s", field.name, field.value.Type())
+		}
+		values = values[:0]
+		for i := range field.value.Len() {
+			values = append(values, field.value.Index(i))
+		}
+	} else if len(matched) == 0 && (field.omitEmpty && field.value.IsZero() || isNilPointer(field.value) || holdsDefault(field) || e.inherits(field)) {
+		return nil
+	} else if len(matched) > 1 {
+		// a single value updates the occurrence a lookup would return
+		matched = []*KeyValueLine{pick(e.file.Lookup.DuplicateKeys, matched)}
+	}
+
+	for i, value := range values {
+		if i < len(matched) {
+			if err := updateValue(e.section, matched[i], value); err != nil {
+				return err
+			}
+			continue
+		}
+		text, err := formatValue(value)
+		if err != nil {
+			return fmt.Errorf("cannot encode key %q: %w", field.name, err)
+		}
+		if i == 0 {
+			section, err := e.ensure()
+			if err != nil {
+				return err
+			}
+			if err := e.file.Set(section.Name(), field.name, text); err != nil {
+				return err
+			}
+			matched = append(matched, section.Key(field.name))
+			continue
+		}
+		// append further occurrences after the last one
+		if !e.file.isValidValue(text) {
+			return fmt.Errorf("cannot encode key %q: invalid value %q", field.name, text)
+		}
+		previous := matched[i-1]
+		line := newKeyValueLine(previous.Name(), e.file.Lookup.escapeReferences(text), previous)
+		e.file.InsertAfter(previous, line)
+		matched = append(matched, line)
+	}
+
+	// remove surplus occurrences of a repeated key
+	for _, line := range matched[len(values):] {
+		e.file.Remove(line)
+	}
+	return nil
+}
+
+// inherits checks if the field holds the value of the matching key in the
+// default section, so it need not be written to the section itself
+func (e *sectionEncoder) inherits(field boundField) bool {
+	if e.section == nil {
+		return false
+	}
+	fallback := e.section.fallback()
+	if fallback == nil {
+		return false
+	}
+	for _, key := range fallback.Keys() {
+		if field.matches(key.Name(), e.file.Lookup.keyName) {
+			return hasValue(e.section, key, field.value)
+		}
+	}
+	return false
+}
+
+// holdsDefault checks if the field holds the value Decode stores for a missing
+// key, its default or else the zero value, so it need not be written
+func holdsDefault(field boundField) bool {
+	if !field.hasDefault {
+		return field.value.IsZero()
+	}
+	def := reflect.New(field.value.Type()).Elem()
+	return setValue(def, field.def) == nil && reflect.DeepEqual(def.Interface(), field.value.Interface())
+}
+
+// hasValue checks if the value of `line`, as read through section `s`, decodes to `v`
+func hasValue(s *Section, line *KeyValueLine, v reflect.Value) bool {
+	value, err := s.valueOf(line)
+	if err != nil {
+		return false
+	}
+	current := reflect.New(v.Type()).Elem()
+	err = setValue(current, value)
+	return err == nil && reflect.DeepEqual(current.Interface(), v.Interface())
+}
+
+// updateValue sets the value of `line` in section `s` to `v`, unless its
+// current value already decodes to the same value
+func updateValue(s *Section, line *KeyValueLine, v reflect.Value) error {
+	if isNilPointer(v) || hasValue(s, line, v) {
+		return nil
+	}
+
+	text, err := formatValue(v)
+	if err != nil {
+		return fmt.Errorf("cannot encode key %q: %w", line.Name(), err)
+	}
+	if !s.file.isValidValue(text) {
+		return fmt.Errorf("cannot encode key %q: invalid value %q", line.Name(), text)
+	}
+	line.SetValue(s.file.Lookup.escapeReferences(text))
+	return nil
+}
+
+// isNilPointer checks if `v` is a nil pointer
+func isNilPointer(v reflect.Value) bool {
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// formatValue converts `v` to the text of an INI value
+func formatValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		return formatValue(v.Elem())
+	}
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	v = reflect.Indirect(v)
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	if v.Type() == durationType {
+		return v.Interface().(fmt.Stringer).String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
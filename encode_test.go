@@ -0,0 +1,159 @@
+package montoya
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const encodeExample = `; managed by hand
+[server]
+  host   = example.com ; the public name
+  port   = 0x1F90
+  verbose = yes
+  allow = 10.0.0.1
+  allow = 10.0.0.2
+
+[unrelated]
+keep = me
+`
+
+// Test only changed values are rewritten, leaving all formatting intact
+func TestEncodeChangesOnlyDifferences(t *testing.T) {
+	file, err := testParse(encodeExample)
+	require.NoError(t, err)
+
+	var config testConfig
+	require.NoError(t, file.Decode(&config))
+	config.Database = nil
+	config.Server.Host = "example.org"
+
+	require.NoError(t, file.Encode(&config))
+	output := readAll(t, file)
+
+	// port 0x1F90 == 8080 and yes == true are left as written
+	assert.Contains(t, output, "  host   = example.org ; the public name\n")
+	assert.Contains(t, output, "  port   = 0x1F90\n")
+	assert.Contains(t, output, "  verbose = yes\n")
+	assert.Contains(t, output, "[unrelated]\nkeep = me\n")
+}
+
+// Test missing keys and sections are appended in the style of the file
+func TestEncodeAddsMissing(t *testing.T) {
+	file, err := testParse(encodeExample)
+	require.NoError(t, err)
+
+	config := testConfig{}
+	require.NoError(t, file.Decode(&config))
+	config.Server.Timeout = 5 * time.Second
+	config.Database = &struct{ Name string }{Name: "main"}
+	config.Plugins = map[string]map[string]string{"plugin-z": {"enabled": "false"}}
+
+	require.NoError(t, file.Encode(config))
+
+	// new keys copy their siblings, new sections copy the first key in the file
+	assert.Equal(t, `; managed by hand
+[server]
+  host   = example.com ; the public name
+  port   = 0x1F90
+  verbose = yes
+  allow = 10.0.0.1
+  allow = 10.0.0.2
+  timeout = 5s
+
+[unrelated]
+keep = me
+
+[database]
+  Name   = main
+
+[plugin-z]
+  enabled   = false
+`, readAll(t, file))
+
+	var reread testConfig
+	require.NoError(t, Unmarshal([]byte(readAll(t, file)), &reread))
+	assert.Equal(t, 5*time.Second, reread.Server.Timeout)
+	assert.Equal(t, "main", reread.Database.Name)
+	assert.Equal(t, "false", reread.Plugins["plugin-z"]["enabled"])
+}
+
+// Test a decoded struct that is not changed encodes back to the same file
+func TestEncodeUnchanged(t *testing.T) {
+	for _, input := range []string{encodeExample, decodeExample} {
+		file, err := testParse(input)
+		require.NoError(t, err)
+
+		var config testConfig
+		require.NoError(t, file.Decode(&config))
+		require.NoError(t, file.Encode(&config))
+		assert.Equal(t, input, readAll(t, file))
+	}
+
+	// a value other than the default is still added
+	file, err := testParse(decodeExample)
+	require.NoError(t, err)
+	var config testConfig
+	require.NoError(t, file.Decode(&config))
+	config.Server.Port = 0
+	require.NoError(t, file.Encode(&config))
+	assert.Equal(t, "0", file.Get("server", "port").Value.Value())
+}
+
+// Test repeated keys bound to a slice are updated, appended and removed
+func TestEncodeRepeatedKeys(t *testing.T) {
+	type section struct {
+		Allow []string `ini:"allow"`
+	}
+	type config struct {
+		S section `ini:"s"`
+	}
+
+	file, err := testParse("[s]\nallow = a ; first\nother = x\nallow = b\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Encode(config{S: section{Allow: []string{"a", "c", "d"}}}))
+	assert.Equal(t, "[s]\nallow = a ; first\nother = x\nallow = c\nallow = d\n", readAll(t, file))
+
+	require.NoError(t, file.Encode(config{S: section{Allow: []string{"z"}}}))
+	assert.Equal(t, "[s]\nallow = z ; first\nother = x\n", readAll(t, file))
+}
+
+// Test omitempty fields are not added, and empty sections are not created
+func TestEncodeOmitEmpty(t *testing.T) {
+	type config struct {
+		S struct {
+			Name  string `ini:"name,omitempty"`
+			Count int    `ini:"count,omitempty"`
+		} `ini:"s"`
+	}
+
+	file, err := testParse("[other]\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Encode(config{}))
+	assert.Equal(t, "[other]\n", readAll(t, file))
+
+	assert.Error(t, file.Encode(42))
+}
+
+// Test untagged fields encode into the section they decode from, regardless of case
+func TestEncodeUntaggedSection(t *testing.T) {
+	type config struct {
+		Server struct {
+			Host string
+		}
+	}
+
+	file, err := testParse("[server]\nhost = a\n")
+	require.NoError(t, err)
+
+	var c config
+	require.NoError(t, file.Decode(&c))
+	assert.Equal(t, "a", c.Server.Host)
+	c.Server.Host = "b"
+	require.NoError(t, file.Encode(&c))
+	assert.Equal(t, "[server]\nhost = b\n", readAll(t, file))
+}
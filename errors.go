@@ -0,0 +1,103 @@
+package montoya
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode identifies the kind of problem a ParseError reports
+//
+// Codes are stable and may be matched on, unlike error messages.
+type ErrorCode int
+
+const (
+	ErrInvalidLineStart    ErrorCode = iota + 1 // A line starts with a byte that cannot start a key
+	ErrInvalidKey                               // A key contains an illegal byte
+	ErrInvalidAfterKey                          // A key is followed by whitespace and something other than a delimiter
+	ErrMissingEquals                            // A key is not followed by a delimiter
+	ErrIllegalQuote                             // A quote appears where a value may not contain one
+	ErrInvalidValue                             // A value contains an illegal byte
+	ErrTrailingValue                            // A quoted value is followed by something other than whitespace
+	ErrUnterminatedValue                        // A quoted value is not closed before the end of the line
+	ErrCommentInSection                         // A comment symbol appears in between section brackets
+	ErrTrailingSection                          // A section header is followed by something other than a comment
+	ErrUnterminatedSection                      // A section header is not closed before the end of the line
+	ErrDuplicateSection                         // A section appears again, while duplicate sections are rejected
+	ErrDuplicateKey                             // A key appears again in its section, while duplicate keys are rejected
+	ErrInvalidSubsection                        // A subsection in a section header is malformed
+	ErrInvalidSectionName                       // A section name contains a byte that strict section names exclude
+	ErrInvalidLocale                            // A locale after a key is malformed
+)
+
+// errorCodeNames holds the names returned by ErrorCode.String
+var errorCodeNames = map[ErrorCode]string{
+	ErrInvalidLineStart:    "invalid line start",
+	ErrInvalidKey:          "invalid key",
+	ErrInvalidAfterKey:     "invalid after key",
+	ErrMissingEquals:       "missing equals",
+	ErrIllegalQuote:        "illegal quote",
+	ErrInvalidValue:        "invalid value",
+	ErrTrailingValue:       "trailing value",
+	ErrUnterminatedValue:   "unterminated value",
+	ErrCommentInSection:    "comment in section",
+	ErrTrailingSection:     "trailing section",
+	ErrUnterminatedSection: "unterminated section",
+	ErrDuplicateSection:    "duplicate section",
+	ErrDuplicateKey:        "duplicate key",
+	ErrInvalidSubsection:   "invalid subsection",
+	ErrInvalidSectionName:  "invalid section name",
+	ErrInvalidLocale:       "invalid locale",
+}
+
+// String returns a short description of the code
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// ParseError is returned when the input is not a valid INI file
+type ParseError struct {
+	// Line and Column locate the offending byte, both starting at 1
+	Line, Column int
+	// Offset is the offset of the offending byte from the start of the input
+	Offset int
+	// Code identifies the kind of error
+	Code ErrorCode
+	// Byte is the offending byte, zero at the end of the input
+	Byte byte
+	// Msg describes the error
+	Msg string
+	// Source is the source line the error occurred on, without its newline
+	Source []byte
+}
+
+// Error implements the error interface
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s (line:%v, col:%v)", e.Msg, e.Line, e.Column)
+}
+
+// Snippet renders the source line with a caret underneath the offending byte
+//
+// Tabs in front of the offending byte are kept in the caret line, so the caret
+// lines up however the snippet is displayed.
+func (e *ParseError) Snippet() string {
+	source := bytes.TrimSuffix(e.Source, []byte{B_CR})
+
+	var out strings.Builder
+	out.Write(source)
+	out.WriteByte(B_NEWLINE)
+
+	prefix := source[:min(max(e.Column-1, 0), len(source))]
+	for _, r := range string(prefix) {
+		if r == '\t' {
+			out.WriteByte(B_TAB)
+		} else {
+			out.WriteByte(B_SPACE)
+		}
+	}
+	out.WriteByte('^')
+	return out.String()
+}
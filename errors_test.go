@@ -0,0 +1,74 @@
+package montoya
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParseError parses the input and returns the resulting ParseError
+func testParseError(t *testing.T, input string) *ParseError {
+	t.Helper()
+	_, err := testParse(input)
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr), "input: %q, err: %v", input, err)
+	return parseErr
+}
+
+// Test every parse error carries a code, position and the offending byte
+func TestParseErrorCodes(t *testing.T) {
+	cases := []struct {
+		input  string
+		code   ErrorCode
+		line   int
+		column int
+		offset int
+		b      byte
+	}{
+		{"[a]\n=value\n", ErrInvalidLineStart, 2, 1, 4, B_EQUALS},
+		{"ke\x01y = 1\n", ErrInvalidKey, 1, 3, 2, 0x01},
+		{"key x = 1\n", ErrInvalidAfterKey, 1, 5, 4, 'x'},
+		{"key\n", ErrMissingEquals, 1, 4, 3, B_NEWLINE},
+		{"key = a\"b\n", ErrIllegalQuote, 1, 8, 7, B_QUOTE},
+		{"key = a\x00\n", ErrInvalidValue, 1, 8, 7, B_NULL},
+		{"key = \"a\" b\n", ErrTrailingValue, 1, 11, 10, 'b'},
+		{"key = \"open\n", ErrUnterminatedValue, 1, 12, 11, B_NEWLINE},
+		{"[a;b]\n", ErrCommentInSection, 1, 3, 2, B_SEMICOLON},
+		{"[a] b\n", ErrTrailingSection, 1, 5, 4, 'b'},
+		{"\xEF\xBB\xBF[a", ErrUnterminatedSection, 1, 3, 5, B_NULL},
+	}
+
+	for _, c := range cases {
+		err := testParseError(t, c.input)
+		assert.Equal(t, c.code, err.Code, "input: %q", c.input)
+		assert.Equal(t, c.line, err.Line, "input: %q", c.input)
+		assert.Equal(t, c.column, err.Column, "input: %q", c.input)
+		assert.Equal(t, c.offset, err.Offset, "input: %q", c.input)
+		assert.Equal(t, c.b, err.Byte, "input: %q", c.input)
+	}
+}
+
+// Test the source line of an error is complete, even when the error occurs halfway
+func TestParseErrorSource(t *testing.T) {
+	err := testParseError(t, "[a]\r\nkey = a\"b c\r\nnext = 1\r\n")
+	assert.Equal(t, "key = a\"b c\r", string(err.Source))
+	assert.Equal(t, "key = a\"b c\n       ^", err.Snippet())
+	assert.Equal(t, "illegal quote character in value (line:2, col:8)", err.Error())
+}
+
+// Test the snippet caret lines up with tabs and multi-byte characters
+func TestParseErrorSnippet(t *testing.T) {
+	err := testParseError(t, "\tké = \"a\" b")
+	assert.Equal(t, "\tké = \"a\" b\n\t         ^", err.Snippet())
+
+	err = testParseError(t, "[open\n")
+	assert.Equal(t, "[open\n     ^", err.Snippet())
+}
+
+// Test error codes describe themselves
+func TestErrorCodeString(t *testing.T) {
+	assert.Equal(t, "illegal quote", ErrIllegalQuote.String())
+	assert.Equal(t, "ErrorCode(0)", ErrorCode(0).String())
+}
@@ -2,6 +2,7 @@ package montoya
 
 import (
 	"math/rand"
+	"slices"
 )
 
 // fuzzFromSet creates a number of fuzzy bytes from the set
@@ -18,6 +19,16 @@ func fuzzyChoice(choices []byte) byte {
 	return choices[rand.Intn(len(choices))]
 }
 
+// fuzzyChoiceExcluding returns a randomly chosen byte from the input that is not in `excluded`
+func fuzzyChoiceExcluding(choices []byte, excluded ...byte) byte {
+	for {
+		choice := fuzzyChoice(choices)
+		if !slices.Contains(excluded, choice) {
+			return choice
+		}
+	}
+}
+
 // fuzzWhiteSpace creates a bytestring of whitespace for testing
 func fuzzWhiteSpace(numChars int) (whitespace []byte) {
 	for range numChars {
@@ -41,6 +52,7 @@ func fuzzComment() (comment []byte) {
 //
 // If `includeBrackets` is set, adds brackets
 func fuzzSection(includeBrackets bool) (section []byte) {
+	section = []byte{}
 	if includeBrackets {
 		section = append(section, B_BRACKET)
 	}
@@ -61,16 +73,20 @@ func fuzzValue(useQuotes bool) (value []byte) {
 	if useQuotes {
 		value = append(value, B_QUOTE)
 	}
-	n := rand.Intn(100)
+	n := 1 + rand.Intn(99)
 	for range n {
 		if useQuotes {
 			newByte := fuzzyChoice(validValueByteSetQuoted)
-			if newByte == B_QUOTE {
+			if newByte == B_QUOTE || newByte == B_BACKSLASH {
+				// escape quotes and backslashes so the closing quote is never escaped
 				value = append(value, B_BACKSLASH)
-				value = append(value, B_QUOTE)
+				value = append(value, newByte)
 			} else {
 				value = append(value, newByte)
 			}
+		} else if len(value) == 0 {
+			// an unquoted value starts with a non-whitespace byte
+			value = append(value, fuzzyChoiceExcluding(validValueByteSetUnquoted, validWhitespaceByteSet...))
 		} else {
 			value = append(value, fuzzyChoice(validValueByteSetUnquoted))
 		}
@@ -82,8 +98,11 @@ func fuzzValue(useQuotes bool) (value []byte) {
 	return
 }
 
+// fuzzKey creates a fuzzy key
 func fuzzKey() (key []byte) {
-	for range 100 {
+	// a key may not start with a comment symbol, as the line would be a comment
+	key = append(key, fuzzyChoiceExcluding(validKeyByteSet, commentStartBytes...))
+	for range 99 {
 		key = append(key, fuzzyChoice(validKeyByteSet))
 	}
 	return key
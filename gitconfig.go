@@ -0,0 +1,38 @@
+package montoya
+
+import "strings"
+
+// GitConfig returns the options for parsing git config files, such as
+// `.git/config` and `~/.gitconfig`
+//
+// Headers may have a quoted subsection, as in `[remote "origin"]`, which is
+// looked up as `remote.origin`. Section and key names match regardless of
+// case, subsection names do not. Values may have quoted parts anywhere and
+// continue on the next line after a backslash. A bare key is a true boolean,
+// and a key may have multiple values: Get returns the last, GetAll returns
+// every one and Add appends another.
+func GitConfig() Options {
+	return Options{
+		BareKeys:              true,
+		Subsections:           true,
+		EmbeddedQuotes:        true,
+		BackslashContinuation: true,
+		Lookup: LookupOptions{
+			DuplicateSections: MergeDuplicates,
+			DuplicateKeys:     MergeDuplicates,
+			NormalizeSection:  gitSectionName,
+			NormalizeKey:      FoldASCII.fold,
+		},
+	}
+}
+
+// gitSectionName normalizes a section name the way git config does, folding
+// the case of the section but not of the subsection
+func gitSectionName(name string) string {
+	section, subsection, found := strings.Cut(name, ".")
+	section = FoldASCII.fold(section)
+	if !found {
+		return section
+	}
+	return section + "." + subsection
+}
@@ -0,0 +1,122 @@
+package montoya
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gitConfigExample = `[core]
+	repositoryformatversion = 0
+	filemode = true
+	bare = false
+	ignorecase
+[remote "origin"]
+	url = git@example.com:voidjump/montoya.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[branch "main"]
+	remote = origin
+	merge = refs/heads/main
+[branch "Feature/X"] ; a comment
+	remote = origin
+[alias]
+	lg = log --graph --pretty=format:\"%h %s\" # pretty
+	st = "status  -sb"
+	co = checkout \
+	     --quiet
+[url "https://example.com/a\"b\\c"]
+	insteadOf = ex:
+`
+
+// Test a git config file round trips and is looked up the way git does
+func TestGitConfig(t *testing.T) {
+	file := parseWith(t, gitConfigExample, GitConfig())
+
+	assert.Equal(t, "true", file.Get("CORE", "FileMode").Value.Value())
+	ignoreCase, err := file.GetBool("core", "ignorecase")
+	require.NoError(t, err)
+	assert.True(t, ignoreCase)
+
+	fetch := file.GetAll("remote.origin", "fetch")
+	require.Len(t, fetch, 2)
+	assert.Equal(t, "+refs/tags/*:refs/tags/*", file.Get("remote.origin", "fetch").Value.Value())
+
+	// section names fold case, subsection names do not
+	assert.NotNil(t, file.Section("Branch.main"))
+	assert.Nil(t, file.Section("branch.MAIN"))
+	assert.NotNil(t, file.Section("branch.Feature/X"))
+
+	assert.Equal(t, `log --graph --pretty=format:"%h %s"`, file.Get("alias", "lg").Value.Value())
+	assert.Equal(t, " pretty", string(file.Get("alias", "lg").Comment.Content()))
+	assert.Equal(t, "status  -sb", file.Get("alias", "st").Value.Value())
+	assert.Equal(t, "checkout       --quiet", file.Get("alias", "co").Value.Value())
+
+	header := file.Section(`url.https://example.com/a"b\c`).Header
+	require.NotNil(t, header)
+	assert.Equal(t, "url", string(header.Header.Content()))
+	assert.Equal(t, `https://example.com/a"b\c`, header.Subsection.Value())
+	assert.Equal(t, span(20, 6, 445, 35, 474), header.Subsection.Span())
+}
+
+// Test editing a git config file keeps its style
+func TestGitConfigEdit(t *testing.T) {
+	file := parseWith(t, "[remote \"origin\"]\n\tfetch = a\n\turl = b\n", GitConfig())
+
+	require.NoError(t, file.Add("remote.origin", "fetch", "c"))
+	require.NoError(t, file.Set("remote.origin", "prune", "a # b"))
+	require.NoError(t, file.Section("remote.origin").Rename(`remote.up"stream`))
+	_, err := file.AddSection("branch.main")
+	require.NoError(t, err)
+	assert.Equal(t, "[remote \"up\\\"stream\"]\n\tfetch = a\n\tfetch = c\n\turl = b\n\tprune = \"a # b\"\n[branch \"main\"]\n", readAll(t, file))
+
+	file = parseWith(t, "[a]\n\tkey = \"x\"\n", GitConfig())
+	file.Get("a", "key").SetValue(`C:\dir`)
+	assert.Equal(t, "[a]\n\tkey = C:\\\\dir\n", readAll(t, file))
+	assert.Equal(t, `C:\dir`, file.Get("a", "key").Value.Value())
+
+	// a bare key gains a value in the syntax of the file
+	file = parseWith(t, "[a]\n\tflag\n", GitConfig())
+	file.Get("a", "flag").SetValue(`C:\dir`)
+	file = parseWith(t, readAll(t, file), GitConfig())
+	assert.Equal(t, `C:\dir`, file.Get("a", "flag").Value.Value())
+}
+
+// Test malformed subsections are rejected
+func TestGitConfigInvalidSubsection(t *testing.T) {
+	for _, input := range []string{"[remote\"origin\"]\n", "[remote \"origin\" x]\n", "[ \"origin\"]\n"} {
+		_, _, err := ParseWithOptions(bytesReader(input), GitConfig())
+		var parseErr *ParseError
+		require.True(t, errors.As(err, &parseErr), "input: %q", input)
+		assert.Equal(t, ErrInvalidSubsection, parseErr.Code, "input: %q", input)
+	}
+
+	_, _, err := ParseWithOptions(bytesReader("[remote \"open]\n"), GitConfig())
+	assert.ErrorContains(t, err, "not properly terminated")
+
+	// without subsections the quotes are part of the name
+	file := parseWith(t, "[remote \"origin\"]\n", Options{})
+	assert.Equal(t, `remote "origin"`, file.Sections()[0].Name())
+}
+
+// Test bare keys decode as booleans
+func TestGitConfigDecode(t *testing.T) {
+	var config struct {
+		Core struct {
+			Bare       bool
+			IgnoreCase *bool `ini:"ignorecase"`
+		} `ini:"core"`
+		Origin struct {
+			Fetch []string `ini:"fetch"`
+		} `ini:"remote.origin"`
+	}
+
+	file := parseWith(t, gitConfigExample, GitConfig())
+	require.NoError(t, file.Decode(&config))
+	assert.False(t, config.Core.Bare)
+	require.NotNil(t, config.Core.IgnoreCase)
+	assert.True(t, *config.Core.IgnoreCase)
+	assert.Len(t, config.Origin.Fetch, 2)
+}
@@ -8,9 +8,38 @@ type IniFile struct {
 	Head IniLine
 	// The end of the file
 	Tail IniLine
+	// BOM indicates whether the file starts with a UTF-8 byte order mark
+	BOM bool
+	// Lookup configures how sections and keys are looked up by name
+	Lookup LookupOptions
+
+	// options holds the options the file was parsed with
+	options Options
 
 	readLine IniLine
-	done     bool
+	// readPrefix holds bytes to be read before the first line
+	readPrefix []byte
+	started    bool
+	done       bool
+}
+
+// FinalNewline reports whether the last line of the file ends in a newline
+func (f *IniFile) FinalNewline() bool {
+	return f.Tail != nil && f.Tail.base().newline
+}
+
+// SetFinalNewline adds or removes the newline at the end of the last line,
+// following the line endings used in the rest of the file
+func (f *IniFile) SetFinalNewline(newline bool) {
+	if f.Tail == nil {
+		return
+	}
+	if newline {
+		terminateLine(f.Tail, f.usesCRLF())
+	} else {
+		unterminateLine(f.Tail)
+	}
+	f.Reset()
 }
 
 // Reset all reader state, prepare to be Read again
@@ -19,47 +48,69 @@ func (f *IniFile) Reset() {
 		line.Reset()
 	}
 	f.readLine = nil
+	f.readPrefix = nil
+	f.started = false
 	f.done = false
 }
 
 // Read file contents to a slice
 func (f *IniFile) Read(dst []byte) (int, error) {
-	if f.readLine == nil && !f.done {
+	if !f.started {
+		f.started = true
 		f.readLine = f.Head
+		if f.BOM {
+			f.readPrefix = utf8BOM
+		}
 	}
 
-	if f.readLine == nil {
-		return 0, io.EOF // empty file or Read after finish
-	}
+	totalWritten := copy(dst, f.readPrefix)
+	f.readPrefix = f.readPrefix[totalWritten:]
 
-	totalWritten := 0
 	for totalWritten < len(dst) && f.readLine != nil {
 		n, err := f.readLine.Read(dst[totalWritten:])
 		totalWritten += n
 
 		if err == io.EOF {
-			if f.readLine == f.Tail {
-				// reached end of last line
-				f.readLine = nil
-				f.done = true
-				if totalWritten == 0 {
-					return 0, io.EOF
-				}
-				return totalWritten, nil
-			}
-			// advance to next line
+			// advance to next line, the last line has no successor
 			f.readLine = f.readLine.Next()
 			continue
 		}
 		if err != nil {
 			return totalWritten, err
 		}
-
-		// line produced data, break to return immediately
-		if n > 0 {
-			break
-		}
 	}
 
+	if f.readLine == nil && len(f.readPrefix) == 0 {
+		f.done = true
+	}
+	if totalWritten == 0 && f.done {
+		return 0, io.EOF // empty file or Read after finish
+	}
 	return totalWritten, nil
 }
+
+// WriteTo writes the file contents to w, implementing io.WriterTo
+//
+// The output is byte-for-byte identical to the parsed input, save for any
+// modifications made to the file since.
+func (f *IniFile) WriteTo(w io.Writer) (int64, error) {
+	f.Reset()
+	defer f.Reset()
+
+	var total int64
+	if f.BOM {
+		n, err := w.Write(utf8BOM)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	for line := f.Head; line != nil; line = line.Next() {
+		n, err := io.Copy(w, line)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,174 @@
+package montoya
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzFile creates a random, valid ini file out of fuzzy lines
+func fuzzFile() []byte {
+	var buf bytes.Buffer
+	if rand.Intn(4) == 0 {
+		buf.Write(utf8BOM)
+	}
+	lineEnd := []byte{B_NEWLINE}
+	if rand.Intn(2) == 0 {
+		lineEnd = []byte{B_CR, B_NEWLINE}
+	}
+
+	n := rand.Intn(20)
+	for i := range n {
+		buf.Write(fuzzWhiteSpace(rand.Intn(4)))
+		switch rand.Intn(4) {
+		case 0:
+			// empty line, optionally with a comment
+		case 1:
+			buf.Write(fuzzSection(true))
+			buf.Write(fuzzWhiteSpace(rand.Intn(4)))
+		default:
+			buf.Write(fuzzKey())
+			buf.Write(fuzzWhiteSpace(rand.Intn(4)))
+			buf.WriteByte(B_EQUALS)
+			buf.Write(fuzzWhiteSpace(rand.Intn(4)))
+			buf.Write(fuzzValue(rand.Intn(2) == 0))
+		}
+		if rand.Intn(3) == 0 {
+			buf.Write(fuzzComment())
+		}
+		// the final line may lack a newline
+		if i < n-1 || rand.Intn(2) == 0 {
+			buf.Write(lineEnd)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Test parsing and reading back generated files is the identity
+func TestRoundTripProperty(t *testing.T) {
+	for range 500 {
+		input := fuzzFile()
+
+		file, err := Parse(bytes.NewReader(input))
+		require.NoError(t, err, "input: %q", input)
+
+		output, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, string(input), string(output))
+
+		var buf bytes.Buffer
+		n, err := file.WriteTo(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(input)), n)
+		require.Equal(t, string(input), buf.String())
+	}
+}
+
+// Test round-tripping files with different line endings and markers
+func TestRoundTripExamples(t *testing.T) {
+	cases := map[string]string{
+		"empty":              "",
+		"newline only":       "\n",
+		"blank lines":        "\n\n  \n\t\n",
+		"no final newline":   "[section]\nkey=value",
+		"crlf":               "; comment\r\n[section] # trailing\r\nkey = \"quoted # value\" ; comment\r\n",
+		"crlf no final":      "[section]\r\nkey=value\r",
+		"bom":                "\xEF\xBB\xBF[section]\nkey=value\n",
+		"bom only":           "\xEF\xBB\xBF",
+		"bom prefix only":    "\xEF\xBB=value",
+		"padding everywhere": "  [ a b ]  \n\tkey\t =\t value\t\n",
+		"empty values":       "a=\nb =\nc= \"\"\n",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			file, err := Parse(bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+
+			output, err := io.ReadAll(file)
+			require.NoError(t, err)
+			assert.Equal(t, input, string(output))
+
+			// Reading again after a reset yields the same result
+			file.Reset()
+			output, err = io.ReadAll(iotest.OneByteReader(file))
+			require.NoError(t, err)
+			assert.Equal(t, input, string(output))
+		})
+	}
+}
+
+// Test a byte order mark is recorded and not parsed as a key
+func TestParseBOM(t *testing.T) {
+	file, err := testParse(utf8BOM, "[section]\n")
+
+	require.NoError(t, err)
+	assert.True(t, file.BOM)
+	assert.IsType(t, &SectionHeaderLine{}, file.Head)
+}
+
+// Test Tail points at the last line of the file
+func TestParseTail(t *testing.T) {
+	file, err := testParse("a=b\n\nc=d")
+
+	require.NoError(t, err)
+	require.NotNil(t, file.Tail)
+	assert.Nil(t, file.Tail.Next())
+	assert.Equal(t, []byte("c"), file.Tail.(*KeyValueLine).Key.content)
+}
+
+// FuzzRoundTrip checks that any accepted input is written back unchanged
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte("[section]\nkey = value ; comment\n"))
+	f.Add([]byte("\xEF\xBB\xBFkey=\"a \\\" b\"\r\n"))
+	f.Add([]byte("  # comment\n\n[s]"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		file, err := Parse(bytes.NewReader(input))
+		if err != nil {
+			return
+		}
+		output, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, string(input), string(output))
+	})
+}
+
+// Test the end of the input validates the final line like a newline does
+func TestParseUnterminatedAtEOF(t *testing.T) {
+	for _, input := range []string{"[section", "key", "key = \"open", "[a]\nkey = \"open \\\""} {
+		file, err := testParse(input)
+		assert.Error(t, err, "input: %q", input)
+		assert.ErrorContains(t, err, "not properly terminated")
+		assert.Nil(t, file)
+
+		// the same line followed by a newline fails the same way
+		_, err = testParse(input, B_NEWLINE)
+		assert.ErrorContains(t, err, "not properly terminated")
+	}
+}
+
+// Test the final line is linked into the file and the final newline is recorded
+func TestFinalNewline(t *testing.T) {
+	file, err := testParse("[a]\r\nkey = \"value\"")
+	require.NoError(t, err)
+	assert.False(t, file.FinalNewline())
+	assert.Same(t, file.Head.Next(), file.Tail)
+	assert.Equal(t, "value", file.Get("a", "key").Value.Value())
+
+	file.SetFinalNewline(true)
+	assert.True(t, file.FinalNewline())
+	assert.Equal(t, "[a]\r\nkey = \"value\"\r\n", readAll(t, file))
+
+	file.SetFinalNewline(false)
+	assert.Equal(t, "[a]\r\nkey = \"value\"", readAll(t, file))
+
+	file, err = testParse("")
+	require.NoError(t, err)
+	assert.False(t, file.FinalNewline())
+}
@@ -0,0 +1,138 @@
+package montoya
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxInterpolationDepth limits how deeply references in referenced values are
+// resolved, like configparser's MAX_INTERPOLATION_DEPTH
+const maxInterpolationDepth = 10
+
+// InterpolationError is returned when a value cannot be interpolated, see
+// `LookupOptions.Interpolation`
+type InterpolationError struct {
+	// Section and Key identify the value
+	Section, Key string
+	// Msg describes the error
+	Msg string
+}
+
+// Error implements the error interface
+func (e *InterpolationError) Error() string {
+	return fmt.Sprintf("cannot interpolate key %q in section %q: %s", e.Key, e.Section, e.Msg)
+}
+
+// valueOf returns the value of `line` as read through the section, with
+// references to other values interpolated, see `LookupOptions.Interpolation`
+//
+// `line` may be a key of the default section, its references are then
+// resolved in this section.
+func (s *Section) valueOf(line *KeyValueLine) (string, error) {
+	value := line.Value.Value()
+	if s.file.Lookup.Interpolation == NoInterpolation {
+		return value, nil
+	}
+	var out strings.Builder
+	if err := s.interpolate(&out, line.Name(), value, 1); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// interpolate writes `value` of `key` to `out`, replacing the references in it
+// by the values they refer to, which are interpolated in turn
+func (s *Section) interpolate(out *strings.Builder, key, value string, depth int) error {
+	if depth > maxInterpolationDepth {
+		return s.interpolationErr(key, "references are nested too deeply")
+	}
+	symbol, open, close := "%", "(", ")s"
+	if s.file.Lookup.Interpolation == ExtendedInterpolation {
+		symbol, open, close = "$", "{", "}"
+	}
+
+	for value != "" {
+		i := strings.Index(value, symbol)
+		if i < 0 {
+			out.WriteString(value)
+			return nil
+		}
+		out.WriteString(value[:i])
+		value = value[i+1:]
+
+		switch {
+		case strings.HasPrefix(value, symbol):
+			// an escaped symbol
+			out.WriteString(symbol)
+			value = value[1:]
+		case strings.HasPrefix(value, open):
+			end := strings.IndexByte(value, close[0])
+			if end <= 1 || !strings.HasPrefix(value[end:], close) {
+				return s.interpolationErr(key, fmt.Sprintf("bad reference %q", symbol+value))
+			}
+			reference := value[1:end]
+			value = value[end+len(close):]
+
+			section, line, err := s.resolve(key, reference)
+			if err != nil {
+				return err
+			}
+			referenced := line.Value.Value()
+			if !strings.Contains(referenced, symbol) {
+				out.WriteString(referenced)
+				continue
+			}
+			if err := section.interpolate(out, line.Name(), referenced, depth+1); err != nil {
+				return err
+			}
+		default:
+			return s.interpolationErr(key, fmt.Sprintf("%q must be followed by %q or %q", symbol, symbol, open))
+		}
+	}
+	return nil
+}
+
+// resolve looks up the key `reference` refers to, together with the section
+// its value is interpolated in
+//
+// With ExtendedInterpolation, a reference of the form `section:key` refers to
+// a key in another section.
+func (s *Section) resolve(key, reference string) (*Section, *KeyValueLine, error) {
+	section, name := s, reference
+	if s.file.Lookup.Interpolation == ExtendedInterpolation {
+		parts := strings.Split(reference, ":")
+		if len(parts) > 2 {
+			return nil, nil, s.interpolationErr(key, fmt.Sprintf("more than one ':' in reference %q", reference))
+		}
+		if len(parts) == 2 {
+			section, name = s.file.Section(parts[0]), parts[1]
+		}
+	}
+
+	var line *KeyValueLine
+	if section != nil {
+		line = section.Key(name)
+	}
+	if line == nil {
+		return nil, nil, s.interpolationErr(key, fmt.Sprintf("missing key %q", reference))
+	}
+	return section, line, nil
+}
+
+// escapeReferences escapes every symbol in `value` that would start a
+// reference, so that the value interpolates to itself, see
+// `LookupOptions.Interpolation`
+func (o LookupOptions) escapeReferences(value string) string {
+	switch o.Interpolation {
+	case BasicInterpolation:
+		return strings.ReplaceAll(value, "%", "%%")
+	case ExtendedInterpolation:
+		return strings.ReplaceAll(value, "$", "$$")
+	}
+	return value
+}
+
+// interpolationErr returns an InterpolationError for `key` in the section
+func (s *Section) interpolationErr(key, msg string) *InterpolationError {
+	return &InterpolationError{Section: s.Name(), Key: key, Msg: msg}
+}
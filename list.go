@@ -0,0 +1,226 @@
+package montoya
+
+import (
+	"bytes"
+	"io"
+	"iter"
+)
+
+// Lines returns an iterator over all lines in the file
+//
+// The current line may be removed or moved while iterating; iteration then
+// continues with the line that followed it.
+func (f *IniFile) Lines() iter.Seq[IniLine] {
+	return func(yield func(IniLine) bool) {
+		for line := f.Head; line != nil; {
+			prev, next := line.Previous(), line.Next()
+			if !yield(line) {
+				return
+			}
+			// follow the current line if it stayed in place, so lines inserted
+			// or removed after it are picked up
+			if f.contains(line) && line.Previous() == prev {
+				next = line.Next()
+			}
+			line = next
+		}
+	}
+}
+
+// contains checks if `line` is linked into the file
+func (f *IniFile) contains(line IniLine) bool {
+	return line.Previous() != nil || f.Head == line
+}
+
+// InsertAfter links `line` into the file directly after `mark`
+//
+// A nil `mark` inserts the line at the start of the file. The line must not
+// already be part of a file. It is given the line ending used by the file.
+func (f *IniFile) InsertAfter(mark, line IniLine) {
+	if mark == nil {
+		f.insertBetween(nil, f.Head, line)
+		return
+	}
+	f.insertBetween(mark, mark.Next(), line)
+}
+
+// InsertBefore links `line` into the file directly before `mark`
+//
+// A nil `mark` inserts the line at the end of the file. The line must not
+// already be part of a file. It is given the line ending used by the file.
+func (f *IniFile) InsertBefore(mark, line IniLine) {
+	if mark == nil {
+		f.insertBetween(f.Tail, nil, line)
+		return
+	}
+	f.insertBetween(mark.Previous(), mark, line)
+}
+
+// Remove unlinks `line` from the file
+//
+// Lines that are not part of the file are ignored.
+func (f *IniFile) Remove(line IniLine) {
+	if !f.contains(line) {
+		return
+	}
+	prev, next := line.Previous(), line.Next()
+	if prev != nil {
+		prev.SetNext(next)
+	} else {
+		f.Head = next
+	}
+	if next != nil {
+		next.SetPrev(prev)
+	} else {
+		f.Tail = prev
+		if prev != nil && !line.base().newline {
+			// the file did not end in a newline, keep it that way
+			unterminateLine(prev)
+		}
+	}
+
+	line.SetPrev(nil)
+	line.SetNext(nil)
+	line.Reset()
+	f.Reset()
+}
+
+// MoveBefore moves `line` so it directly precedes `mark`
+func (f *IniFile) MoveBefore(line, mark IniLine) {
+	if line == mark {
+		return
+	}
+	f.Remove(line)
+	f.InsertBefore(mark, line)
+}
+
+// MoveAfter moves `line` so it directly follows `mark`
+func (f *IniFile) MoveAfter(line, mark IniLine) {
+	if line == mark {
+		return
+	}
+	f.Remove(line)
+	f.InsertAfter(mark, line)
+}
+
+// insertBetween links `line` in between `prev` and `next`, either of which
+// may be nil at the start or end of the file
+func (f *IniFile) insertBetween(prev, next, line IniLine) {
+	crlf := f.usesCRLF()
+	if next == nil && prev != nil && !prev.base().newline {
+		// the file does not end in a newline, so the new last line does not either
+		terminateLine(prev, crlf)
+		unterminateLine(line)
+	} else {
+		terminateLine(line, crlf)
+	}
+
+	line.SetPrev(prev)
+	line.SetNext(next)
+	if prev != nil {
+		prev.SetNext(line)
+	} else {
+		f.Head = line
+	}
+	if next != nil {
+		next.SetPrev(line)
+	} else {
+		f.Tail = line
+	}
+	f.Reset()
+}
+
+// lineBytes returns the content of a line as it is written out
+func lineBytes(line IniLine) []byte {
+	line.Reset()
+	defer line.Reset()
+	content, _ := io.ReadAll(line)
+	return content
+}
+
+// usesCRLF checks if the lines of the file end in CRLF, judging by the first
+// line terminated by a newline
+func (f *IniFile) usesCRLF() bool {
+	for line := f.Head; line != nil; line = line.Next() {
+		if line.base().newline {
+			return usesCRLF(line)
+		}
+	}
+	return false
+}
+
+// usesCRLF checks if the line ends in a carriage return before the newline
+func usesCRLF(line IniLine) bool {
+	content := bytes.TrimSuffix(lineBytes(line), []byte{B_NEWLINE})
+	return len(content) > 0 && content[len(content)-1] == B_CR
+}
+
+// terminateLine adds a newline to a line that is not yet terminated, with a
+// carriage return in front if `crlf` is set
+func terminateLine(line IniLine, crlf bool) {
+	base := line.base()
+	if base.newline {
+		return
+	}
+	base.newline = true
+	if !crlf {
+		return
+	}
+	if trailing := trailingNodeContent(line); trailing != nil {
+		*trailing = append(*trailing, B_CR)
+	}
+	line.Reset()
+}
+
+// unterminateLine removes the newline from a line, including a carriage
+// return in front of it
+func unterminateLine(line IniLine) {
+	base := line.base()
+	if !base.newline {
+		return
+	}
+	base.newline = false
+	if trailing := trailingNodeContent(line); trailing != nil {
+		*trailing = bytes.TrimSuffix(*trailing, []byte{B_CR})
+	}
+	line.Reset()
+}
+
+// trailingNodeContent returns the content of the last node on the line, which
+// holds any carriage return before the newline
+//
+// Returns nil for lines that cannot hold trailing whitespace.
+func trailingNodeContent(line IniLine) *[]byte {
+	switch concrete := line.(type) {
+	case *EmptyLine:
+		if concrete.Comment != nil {
+			return &concrete.Comment.content
+		}
+		if concrete.Padding == nil {
+			concrete.Padding = &WhitespaceNode{}
+		}
+		return &concrete.Padding.content
+	case *SectionHeaderLine:
+		if concrete.Comment != nil {
+			return &concrete.Comment.content
+		}
+		// an unterminated header has no PostPad, and cannot hold whitespace
+		if concrete.PostPad != nil {
+			return &concrete.PostPad.content
+		}
+	case *KeyValueLine:
+		if concrete.Comment != nil {
+			return &concrete.Comment.content
+		}
+		if concrete.Value != nil {
+			return &concrete.Value.content
+		}
+		if concrete.PostKeyPad == nil {
+			concrete.PostKeyPad = &WhitespaceNode{}
+		}
+		return &concrete.PostKeyPad.content
+	case *InvalidLine:
+		return &concrete.Raw
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package montoya
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineNames returns the key or section name of every line, or "" for empty lines
+func lineNames(file *IniFile) (names []string) {
+	for line := range file.Lines() {
+		switch concrete := line.(type) {
+		case *KeyValueLine:
+			names = append(names, concrete.Name())
+		case *SectionHeaderLine:
+			names = append(names, concrete.Name())
+		default:
+			names = append(names, "")
+		}
+	}
+	return
+}
+
+// assertLinked checks that the links in both directions and head/tail are consistent
+func assertLinked(t *testing.T, file *IniFile) {
+	t.Helper()
+	var prev IniLine
+	for line := file.Head; line != nil; line = line.Next() {
+		assert.Equal(t, prev, line.Previous())
+		prev = line
+	}
+	assert.Equal(t, prev, file.Tail)
+}
+
+// Test inserting lines before and after other lines and at the ends of the file
+func TestInsertLines(t *testing.T) {
+	file, err := testParse("b=2\nd=4\n")
+	require.NoError(t, err)
+
+	file.InsertAfter(file.Head, newKeyValueLine("c", "3", nil))
+	file.InsertBefore(file.Head, newKeyValueLine("a", "1", nil))
+	file.InsertBefore(nil, newKeyValueLine("e", "5", nil))
+	file.InsertAfter(nil, newKeyValueLine("start", "0", nil))
+
+	assertLinked(t, file)
+	assert.Equal(t, []string{"start", "a", "b", "c", "d", "e"}, lineNames(file))
+	assert.Equal(t, "start = 0\na = 1\nb=2\nc = 3\nd=4\ne = 5\n", readAll(t, file))
+}
+
+// Test inserting into an empty file
+func TestInsertIntoEmptyFile(t *testing.T) {
+	file := &IniFile{}
+	file.InsertAfter(nil, newKeyValueLine("a", "1", nil))
+
+	assertLinked(t, file)
+	assert.Equal(t, "a = 1\n", readAll(t, file))
+}
+
+// Test inserted lines follow the line ending style and final newline of the file
+func TestInsertKeepsLineEndings(t *testing.T) {
+	file, err := testParse("a=1\r\nb=2")
+	require.NoError(t, err)
+
+	file.InsertBefore(nil, newKeyValueLine("c", "3", nil))
+	file.InsertAfter(file.Head, newKeyValueLine("x", "0", nil))
+
+	assert.Equal(t, "a=1\r\nx = 0\r\nb=2\r\nc = 3", readAll(t, file))
+}
+
+// Test removing lines keeps links, head and tail consistent
+func TestRemoveLines(t *testing.T) {
+	file, err := testParse("a=1\nb=2\nc=3\nd=4")
+	require.NoError(t, err)
+
+	file.Remove(file.Head)
+	file.Remove(file.Head.Next())
+	assertLinked(t, file)
+	assert.Equal(t, "b=2\nd=4", readAll(t, file))
+
+	// the removed line is unlinked and removing it again is a no-op
+	removed := file.Tail
+	file.Remove(removed)
+	assert.Nil(t, removed.Previous())
+	file.Remove(removed)
+	assertLinked(t, file)
+	assert.Equal(t, "b=2", readAll(t, file))
+
+	file.Remove(file.Head)
+	assert.Nil(t, file.Head)
+	assert.Nil(t, file.Tail)
+	assert.Equal(t, "", readAll(t, file))
+}
+
+// Test moving lines around, including the last line
+func TestMoveLines(t *testing.T) {
+	file, err := testParse("a=1\r\nb=2\r\nc=3")
+	require.NoError(t, err)
+
+	file.MoveBefore(file.Tail, file.Head)
+	assertLinked(t, file)
+	assert.Equal(t, "c=3\r\na=1\r\nb=2", readAll(t, file))
+
+	file.MoveAfter(file.Head, file.Tail)
+	assertLinked(t, file)
+	assert.Equal(t, "a=1\r\nb=2\r\nc=3", readAll(t, file))
+
+	file.MoveAfter(file.Head, file.Head)
+	assert.Equal(t, "a=1\r\nb=2\r\nc=3", readAll(t, file))
+}
+
+// Test edits reset an in-progress Read
+func TestEditResetsRead(t *testing.T) {
+	file, err := testParse("a=1\nb=2\n")
+	require.NoError(t, err)
+
+	buf := make([]byte, 3)
+	_, err = file.Read(buf)
+	require.NoError(t, err)
+
+	file.Remove(file.Head)
+	output := make([]byte, 10)
+	n, _ := file.Read(output)
+	assert.Equal(t, "b=2\n", string(output[:n]))
+}
+
+// Test lines can be removed while iterating
+func TestLinesRemoveWhileIterating(t *testing.T) {
+	file, err := testParse("a=1\n\nb=2\n\n\nc=3\n")
+	require.NoError(t, err)
+
+	for line := range file.Lines() {
+		if _, ok := line.(*EmptyLine); ok {
+			file.Remove(line)
+		}
+	}
+	assertLinked(t, file)
+	assert.Equal(t, "a=1\nb=2\nc=3\n", readAll(t, file))
+
+	// moving the current line does not visit lines twice
+	var visited []string
+	first := file.Head
+	for line := range file.Lines() {
+		visited = append(visited, line.(*KeyValueLine).Name())
+		if line == first && len(visited) == 1 {
+			file.MoveAfter(line, file.Tail)
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, visited)
+	assert.Equal(t, []string{"b", "c", "a"}, lineNames(file))
+}
@@ -0,0 +1,225 @@
+package montoya
+
+// Section is a handle on a section of an IniFile
+//
+// A section consists of its SectionHeaderLine and every line up to the next
+// header. The handle points into the linked list of the file, so lines
+// reached through it may be inspected and edited in place.
+//
+// The lines before the first header form the global section, which has no
+// header and an empty name.
+type Section struct {
+	// file is the IniFile the section is part of
+	file *IniFile
+	// Header is the line that opens the section, nil for the global section
+	Header *SectionHeaderLine
+	// merged holds the header of every occurrence of a section that is merged
+	// under MergeDuplicates, in file order
+	merged []*SectionHeaderLine
+}
+
+// Name returns the name of the section header, without brackets
+//
+// A header with a subsection is named after both, joined by a dot, as in
+// `remote.origin` for `[remote "origin"]`.
+func (l *SectionHeaderLine) Name() string {
+	if l.Subsection != nil {
+		return string(l.Header.Content()) + "." + l.Subsection.Value()
+	}
+	return string(l.Header.Content())
+}
+
+// Name returns the name of the key
+//
+// A localized key is named after the key and its locale, as in `Name[de_DE]`.
+func (l *KeyValueLine) Name() string {
+	return string(l.Key.Content()) + string(l.Locale.Content())
+}
+
+// Name returns the name of the section, which is empty for the global section
+func (s *Section) Name() string {
+	if s.Header == nil {
+		return ""
+	}
+	return s.Header.Name()
+}
+
+// IsGlobal checks if the section is the global section
+func (s *Section) IsGlobal() bool {
+	return s.Header == nil
+}
+
+// headers returns the header of every occurrence the section consists of
+func (s *Section) headers() []*SectionHeaderLine {
+	if len(s.merged) > 0 {
+		return s.merged
+	}
+	return []*SectionHeaderLine{s.Header}
+}
+
+// Keys returns all KeyValueLines in the section, in file order
+//
+// Keys of the default section are not included.
+func (s *Section) Keys() (keys []*KeyValueLine) {
+	for _, header := range s.headers() {
+		keys = append(keys, s.file.headerKeys(header)...)
+	}
+	return
+}
+
+// afterHeader returns the first line after `header`, or the first line of the
+// file for the nil header of the global section
+func (f *IniFile) afterHeader(header *SectionHeaderLine) IniLine {
+	if header == nil {
+		return f.Head
+	}
+	return header.Next()
+}
+
+// headerKeys returns the KeyValueLines after `header` up to the next header
+func (f *IniFile) headerKeys(header *SectionHeaderLine) (keys []*KeyValueLine) {
+	for line := f.afterHeader(header); line != nil; line = line.Next() {
+		switch concrete := line.(type) {
+		case *SectionHeaderLine:
+			return
+		case *KeyValueLine:
+			keys = append(keys, concrete)
+		}
+	}
+	return
+}
+
+// Key returns the KeyValueLine in the section with the given name
+//
+// If the key appears more than once, the occurrence is picked following the
+// duplicate key policy of the file. A key missing from the section is looked
+// up in the default section. Returns nil if neither has such a key.
+func (s *Section) Key(name string) *KeyValueLine {
+	return pick(s.file.Lookup.DuplicateKeys, s.GetAll(name))
+}
+
+// GetAll returns every KeyValueLine in the section with the given name, in
+// file order
+//
+// A key missing from the section is looked up in the default section.
+func (s *Section) GetAll(name string) []*KeyValueLine {
+	if keys := s.ownKeys(name); len(keys) > 0 {
+		return keys
+	}
+	if fallback := s.fallback(); fallback != nil {
+		return fallback.ownKeys(name)
+	}
+	return nil
+}
+
+// ownKeys returns every KeyValueLine in the section with the given name,
+// without falling back to the default section
+func (s *Section) ownKeys(name string) (keys []*KeyValueLine) {
+	name = s.file.Lookup.keyName(name)
+	for _, key := range s.Keys() {
+		if s.file.Lookup.keyName(key.Name()) == name {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+// keysWithDefaults returns the keys of the section followed by the keys of
+// the default section that the section does not override
+func (s *Section) keysWithDefaults() []*KeyValueLine {
+	keys := s.Keys()
+	fallback := s.fallback()
+	if fallback == nil {
+		return keys
+	}
+	own := make(map[string]bool)
+	for _, key := range keys {
+		own[s.file.Lookup.keyName(key.Name())] = true
+	}
+	for _, key := range fallback.Keys() {
+		if !own[s.file.Lookup.keyName(key.Name())] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// fallback returns the default section whose keys act as fallbacks for the
+// section, or nil if there is none
+func (s *Section) fallback() *Section {
+	if s.file.Lookup.DefaultSection == "" || s.isDefault() {
+		return nil
+	}
+	return s.file.Section(s.file.Lookup.DefaultSection)
+}
+
+// isDefault checks if the section is the default section of the file
+func (s *Section) isDefault() bool {
+	lookup := s.file.Lookup
+	return lookup.DefaultSection != "" && lookup.sectionName(s.Name()) == lookup.sectionName(lookup.DefaultSection)
+}
+
+// Sections returns all sections in the file that have a header, in file order
+//
+// A section that appears more than once is returned once for every occurrence.
+func (f *IniFile) Sections() (sections []*Section) {
+	for line := f.Head; line != nil; line = line.Next() {
+		if header, ok := line.(*SectionHeaderLine); ok {
+			sections = append(sections, &Section{file: f, Header: header})
+		}
+	}
+	return
+}
+
+// Global returns the global section, holding the lines before the first header
+//
+// Every file has a global section, even when it is empty.
+func (f *IniFile) Global() *Section {
+	return &Section{file: f}
+}
+
+// Section returns the section in the file with the given name
+//
+// If the section appears more than once, the occurrence is picked following
+// the duplicate section policy of the file. An empty name refers to the
+// global section. Returns nil if the file has no such section.
+func (f *IniFile) Section(name string) *Section {
+	var headers []*SectionHeaderLine
+	name = f.Lookup.sectionName(name)
+	if name == f.Lookup.sectionName("") {
+		// the global section comes before any header
+		headers = append(headers, nil)
+	}
+	for _, section := range f.Sections() {
+		if f.Lookup.sectionName(section.Name()) == name {
+			headers = append(headers, section.Header)
+		}
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	if f.Lookup.DuplicateSections == MergeDuplicates && len(headers) > 1 {
+		return &Section{file: f, Header: headers[0], merged: headers}
+	}
+	return &Section{file: f, Header: pick(f.Lookup.DuplicateSections, headers)}
+}
+
+// Get returns the KeyValueLine for `key` in `section`
+//
+// Returns nil if either the section or the key does not exist.
+func (f *IniFile) Get(section, key string) *KeyValueLine {
+	s := f.Section(section)
+	if s == nil {
+		return nil
+	}
+	return s.Key(key)
+}
+
+// GetAll returns every KeyValueLine for `key` in `section`, in file order
+func (f *IniFile) GetAll(section, key string) []*KeyValueLine {
+	s := f.Section(section)
+	if s == nil {
+		return nil
+	}
+	return s.GetAll(key)
+}
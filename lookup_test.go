@@ -0,0 +1,253 @@
+package montoya
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lookupExample = `global=1
+
+[first]
+a = 1
+; comment
+b = 2
+
+[second] ; trailing comment
+a = 3
+`
+
+// Test sections are found in file order
+func TestSections(t *testing.T) {
+	file, err := testParse(lookupExample)
+	require.NoError(t, err)
+
+	sections := file.Sections()
+	require.Len(t, sections, 2)
+	assert.Equal(t, "first", sections[0].Name())
+	assert.Equal(t, "second", sections[1].Name())
+}
+
+// Test looking up sections by name
+func TestSectionLookup(t *testing.T) {
+	file, err := testParse(lookupExample)
+	require.NoError(t, err)
+
+	section := file.Section("second")
+	require.NotNil(t, section)
+	assert.Equal(t, "second", section.Name())
+
+	assert.Nil(t, file.Section("third"))
+}
+
+// Test keys belong to the section up to the next header
+func TestSectionKeys(t *testing.T) {
+	file, err := testParse(lookupExample)
+	require.NoError(t, err)
+
+	keys := file.Section("first").Keys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, "a", keys[0].Name())
+	assert.Equal(t, "b", keys[1].Name())
+
+	assert.Nil(t, file.Section("first").Key("global"))
+	assert.Nil(t, file.Section("second").Key("b"))
+}
+
+// Test Get returns handles into the linked list
+func TestGet(t *testing.T) {
+	file, err := testParse(lookupExample)
+	require.NoError(t, err)
+
+	key := file.Get("second", "a")
+	require.NotNil(t, key)
+	assert.Equal(t, []byte(" 3"), key.Value.Content())
+	assert.Same(t, file.Tail, IniLine(key))
+
+	assert.Nil(t, file.Get("second", "missing"))
+	assert.Nil(t, file.Get("missing", "a"))
+}
+
+const duplicateExample = `[a]
+key = 1
+key = 2
+[b]
+key = 3
+[a]
+key = 4
+other = 5
+`
+
+// parseDuplicates parses `duplicateExample` with the given policies
+func parseDuplicates(t *testing.T, sections, keys DuplicatePolicy) *IniFile {
+	t.Helper()
+	options := Options{Lookup: LookupOptions{DuplicateSections: sections, DuplicateKeys: keys}}
+	file, _, err := ParseWithOptions(bytesReader(duplicateExample), options)
+	require.NoError(t, err)
+	return file
+}
+
+// values returns the values of the lines
+func values(lines []*KeyValueLine) (result []string) {
+	for _, line := range lines {
+		result = append(result, line.Value.Value())
+	}
+	return
+}
+
+// Test lookups follow the duplicate policies
+func TestDuplicatePolicies(t *testing.T) {
+	file := parseDuplicates(t, FirstWins, FirstWins)
+	assert.Equal(t, "1", file.Get("a", "key").Value.Value())
+	assert.Nil(t, file.Get("a", "other"))
+	assert.Equal(t, []string{"1", "2"}, values(file.GetAll("a", "key")))
+	assert.Len(t, file.Sections(), 3)
+
+	file = parseDuplicates(t, LastWins, LastWins)
+	assert.Equal(t, "4", file.Get("a", "key").Value.Value())
+	assert.Equal(t, "5", file.Get("a", "other").Value.Value())
+	assert.Equal(t, []string{"4"}, values(file.GetAll("a", "key")))
+
+	file = parseDuplicates(t, MergeDuplicates, MergeDuplicates)
+	assert.Equal(t, "4", file.Get("a", "key").Value.Value())
+	assert.Equal(t, []string{"1", "2", "4"}, values(file.GetAll("a", "key")))
+	assert.Len(t, file.Section("a").Keys(), 4)
+
+	file = parseDuplicates(t, MergeDuplicates, FirstWins)
+	assert.Equal(t, "1", file.Get("a", "key").Value.Value())
+	assert.Equal(t, "5", file.Get("a", "other").Value.Value())
+}
+
+// Test duplicates are parse errors when they are rejected
+func TestDuplicatesRejected(t *testing.T) {
+	options := Options{Lookup: LookupOptions{DuplicateKeys: RejectDuplicates}}
+	_, _, err := ParseWithOptions(bytesReader(duplicateExample), options)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrDuplicateKey, parseErr.Code)
+	assert.Equal(t, 3, parseErr.Line)
+	assert.Equal(t, 1, parseErr.Column)
+
+	options = Options{Recover: true, Lookup: LookupOptions{DuplicateSections: RejectDuplicates}}
+	file, diagnostics, err := ParseWithOptions(bytesReader(duplicateExample), options)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, ErrDuplicateSection, diagnostics[0].Code)
+	assert.Equal(t, 6, diagnostics[0].Line)
+	assert.Equal(t, 2, diagnostics[0].Column)
+	assert.Equal(t, "[a]", string(diagnostics[0].Source))
+	// the duplicate is kept, and lookups find the first occurrence
+	assert.Equal(t, duplicateExample, readAll(t, file))
+	assert.Equal(t, "1", file.Get("a", "key").Value.Value())
+
+	// keys of merged sections are checked across occurrences
+	options = Options{Lookup: LookupOptions{DuplicateSections: MergeDuplicates, DuplicateKeys: RejectDuplicates}}
+	_, _, err = ParseWithOptions(bytesReader("[a]\nkey=1\n[a]\nkey=2\n"), options)
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 4, parseErr.Line)
+	_, _, err = ParseWithOptions(bytesReader("[a]\nkey=1\n[b]\nkey=2\n"), options)
+	assert.NoError(t, err)
+}
+
+// Test editing a merged section edits every occurrence
+func TestMergedSectionEdits(t *testing.T) {
+	file := parseDuplicates(t, MergeDuplicates, MergeDuplicates)
+	require.NoError(t, file.Section("a").Rename("c"))
+	assert.Equal(t, []string{"c", "b", "c"}, sectionNames(file))
+
+	file.Section("c").Delete()
+	assert.Equal(t, "[b]\nkey = 3\n", readAll(t, file))
+}
+
+// Test names are matched in normalized form while keeping their spelling
+func TestNormalizedLookup(t *testing.T) {
+	input := "[ Server ]\nHost-Name = a\n[STRASSE]\nKey = b\n"
+	options := Options{Lookup: LookupOptions{
+		CaseFolding:  FoldASCII,
+		TrimSpace:    true,
+		NormalizeKey: func(name string) string { return strings.ReplaceAll(name, "-", "_") },
+	}}
+	file, _, err := ParseWithOptions(bytesReader(input), options)
+	require.NoError(t, err)
+
+	assert.Equal(t, "a", file.Get("server", "host_name").Value.Value())
+	assert.Equal(t, "a", file.Get("SERVER", "HOST-NAME").Value.Value())
+	assert.Nil(t, file.Get(" server", "hostname"))
+
+	require.NoError(t, file.Set("server", "HOST_NAME", "c"))
+	assert.Equal(t, "[ Server ]\nHost-Name = c\n[STRASSE]\nKey = b\n", readAll(t, file))
+
+	assert.Nil(t, file.Section("straße"))
+	file.Lookup.CaseFolding = CaseSensitive
+	assert.Nil(t, file.Get("server", "host_name"))
+	assert.NotNil(t, file.Section(" Server "))
+}
+
+// Test Unicode case folding matches like strings.EqualFold
+func TestUnicodeFolding(t *testing.T) {
+	file, _, err := ParseWithOptions(bytesReader("[Ωmega]\nK = 1\n"), Options{Lookup: LookupOptions{CaseFolding: FoldUnicode}})
+	require.NoError(t, err)
+	assert.NotNil(t, file.Get("ωMEGA", "K")) // Kelvin sign folds to k
+
+	file.Lookup.CaseFolding = FoldASCII
+	assert.Nil(t, file.Section("ωmega"))
+	assert.NotNil(t, file.Section("ΩMEGA"))
+}
+
+// Test duplicates are detected in normalized form
+func TestNormalizedDuplicates(t *testing.T) {
+	options := Options{Lookup: LookupOptions{CaseFolding: FoldASCII, DuplicateKeys: RejectDuplicates}}
+	_, _, err := ParseWithOptions(bytesReader("[a]\nkey = 1\nKEY = 2\n"), options)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrDuplicateKey, parseErr.Code)
+}
+
+// Test keys before the first header belong to the global section
+func TestGlobalSection(t *testing.T) {
+	file, err := testParse(lookupExample)
+	require.NoError(t, err)
+
+	global := file.Global()
+	assert.True(t, global.IsGlobal())
+	assert.Equal(t, "", global.Name())
+	require.Len(t, global.Keys(), 1)
+	assert.Equal(t, "1", global.Key("global").Value.Value())
+	assert.Equal(t, "1", file.Get("", "global").Value.Value())
+	assert.Nil(t, file.Get("", "a"))
+	assert.Len(t, file.Sections(), 2)
+
+	empty, err := testParse("[a]\nkey = 1\n")
+	require.NoError(t, err)
+	assert.Empty(t, empty.Global().Keys())
+	assert.NotNil(t, empty.Section(""))
+}
+
+const defaultExample = `[DEFAULT]
+host = localhost
+port = 80
+
+[web]
+port = 8080
+
+[db]
+`
+
+// Test keys of the default section act as fallbacks for other sections
+func TestDefaultSection(t *testing.T) {
+	options := Options{Lookup: LookupOptions{DefaultSection: "DEFAULT"}}
+	file, _, err := ParseWithOptions(bytesReader(defaultExample), options)
+	require.NoError(t, err)
+
+	assert.Equal(t, "8080", file.Get("web", "port").Value.Value())
+	assert.Equal(t, "localhost", file.Get("web", "host").Value.Value())
+	assert.Equal(t, "80", file.Get("db", "port").Value.Value())
+	assert.Len(t, file.Section("db").Keys(), 0)
+	assert.Equal(t, 80, file.GetIntDefault("db", "port", 0))
+
+	// without the option the default section is a section like any other
+	file.Lookup.DefaultSection = ""
+	assert.Nil(t, file.Get("db", "port"))
+}
@@ -0,0 +1,280 @@
+package montoya
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Options configure how ParseWithOptions parses its input
+//
+// The zero value parses like Parse does.
+type Options struct {
+	// Recover keeps parsing after an error. A line that fails to parse becomes
+	// an InvalidLine holding its raw bytes, and the error is reported as a
+	// diagnostic instead.
+	Recover bool
+
+	// Delimiters holds the bytes that may separate a key from its value, `=`
+	// when empty. Set it to e.g. `=:` to also accept `key: value`. A delimiter
+	// may not be whitespace, a comment symbol, a quote or a bracket, and can
+	// no longer appear in keys.
+	Delimiters []byte
+	// BareKeys accepts keys without a delimiter and value, such as
+	// `skip-networking` in MySQL's my.cnf. They parse as a KeyValueLine
+	// without a Value, and are written back without a delimiter.
+	BareKeys bool
+	// Subsections parses a quoted subsection name after the section name in
+	// a header, as in git config's `[remote "origin"]`. A backslash in the
+	// subsection name escapes the next byte. The section is named
+	// `remote.origin` in lookups.
+	Subsections bool
+	// StrictSectionNames only accepts printable ASCII other than brackets in
+	// section names, comment symbols included, as the desktop entry
+	// specification requires of group names.
+	StrictSectionNames bool
+	// LocaleKeys parses a locale in brackets after a key, as in `Name[de_DE]`
+	// in .desktop files, into the Locale of the KeyValueLine. A locale may
+	// contain ASCII letters, digits, and `_`, `.`, `@` and `-`. The key is
+	// named `Name[de_DE]` in lookups.
+	LocaleKeys bool
+	// EmbeddedQuotes allows quoted parts anywhere in a value, as in git
+	// config's `key = a" # b "c`. Backslash escapes are decoded inside and
+	// outside of quotes, and a comment symbol outside of quotes starts a
+	// comment as decided by InlineComments.
+	EmbeddedQuotes bool
+	// RawValues takes values verbatim, quotes and backslashes included, as
+	// systemd does. Only the whitespace around a value is trimmed. A comment
+	// symbol in a value starts a comment as decided by InlineComments. A
+	// trailing backslash is only special with BackslashContinuation. Without
+	// a way to continue a value, a newline in a value that is set is written
+	// as `\n`, as in desktop entries. Cannot be combined with EmbeddedQuotes.
+	RawValues bool
+
+	// BackslashContinuation continues a value on the next line when its line
+	// ends in a backslash, as in php.ini and git config. The backslash and
+	// newline are dropped from the value. An escaped backslash at the end of
+	// a quoted value does not continue it, nor does a value followed by a
+	// comment.
+	BackslashContinuation bool
+	// IndentedContinuation continues a value on every following line that is
+	// indented deeper than its key and neither blank nor a comment, as in
+	// Python's configparser. The lines of the value are trimmed and joined by
+	// newlines. A value followed by a comment does not continue.
+	IndentedContinuation bool
+	// TripleQuotes allows values in between triple quotes, which may span
+	// multiple lines. Their content is taken verbatim, without escapes, save
+	// for a newline directly after the opening quotes.
+	TripleQuotes bool
+
+	// InlineComments decides whether a comment symbol in an unquoted value
+	// starts a comment. Comment symbols in quoted values never do.
+	InlineComments InlineComments
+
+	// Lookup configures lookups in the parsed file. Duplicates for which the
+	// policy is RejectDuplicates are reported as parse errors.
+	Lookup LookupOptions
+}
+
+// defaultDelimiters separate keys from values when no delimiters are configured
+var defaultDelimiters = []byte{B_EQUALS}
+
+// delimiters returns the configured delimiters, or the default ones
+func (o Options) delimiters() []byte {
+	if len(o.Delimiters) == 0 {
+		return defaultDelimiters
+	}
+	return o.Delimiters
+}
+
+// valueSyntax returns the syntax of the values the options parse
+func (o Options) valueSyntax() valueSyntax {
+	switch {
+	case o.EmbeddedQuotes:
+		return embeddedValue
+	case o.RawValues && o.IndentedContinuation && !o.BackslashContinuation:
+		return rawIndentedValue
+	case o.RawValues && !o.BackslashContinuation && !o.IndentedContinuation:
+		return rawLineValue
+	case o.RawValues:
+		return rawValue
+	}
+	return standardValue
+}
+
+// validate checks the options are consistent
+func (o Options) validate() error {
+	if o.EmbeddedQuotes && o.RawValues {
+		return errors.New("embedded quotes and raw values cannot be combined")
+	}
+	for _, delimiter := range o.Delimiters {
+		if !isValidDelimiter(delimiter) {
+			return fmt.Errorf("invalid delimiter %q", delimiter)
+		}
+	}
+	return nil
+}
+
+// isValidDelimiter checks if `delimiter` can separate a key from its value
+func isValidDelimiter(delimiter byte) bool {
+	if delimiter == B_EQUALS {
+		return true
+	}
+	token := convertToken(delimiter)
+	return isKeyByte(delimiter) && token != CommentStart && token != Quote
+}
+
+// InlineComments decides whether a comment symbol in an unquoted value starts
+// an inline comment
+type InlineComments int
+
+const (
+	// InlineCommentsAlways starts a comment at every comment symbol
+	InlineCommentsAlways InlineComments = iota
+	// InlineCommentsNever takes comment symbols as part of the value, so
+	// e.g. `#ff0000` and URLs with a fragment can be written unquoted
+	InlineCommentsNever
+	// InlineCommentsAfterWhitespace only starts a comment at a comment symbol
+	// preceded by whitespace, like the `inline_comment_prefixes` of Python's
+	// configparser
+	InlineCommentsAfterWhitespace
+)
+
+// DuplicatePolicy decides how lookups treat sections or keys that appear more
+// than once
+type DuplicatePolicy int
+
+const (
+	// FirstWins looks up the first occurrence
+	FirstWins DuplicatePolicy = iota
+	// LastWins looks up the last occurrence
+	LastWins
+	// RejectDuplicates makes a duplicate a parse error. Lookups in a file that
+	// holds duplicates regardless look up the first occurrence.
+	RejectDuplicates
+	// MergeDuplicates merges all occurrences. Sections with the same name act
+	// as a single section. A repeated key acts as a multi-valued key, of which
+	// lookups return the last value.
+	MergeDuplicates
+)
+
+// pick returns the occurrence a lookup following the policy returns
+func pick[T any](policy DuplicatePolicy, occurrences []T) (result T) {
+	if len(occurrences) == 0 {
+		return
+	}
+	if policy == LastWins || policy == MergeDuplicates {
+		return occurrences[len(occurrences)-1]
+	}
+	return occurrences[0]
+}
+
+// CaseFolding decides whether names match regardless of case
+type CaseFolding int
+
+const (
+	// CaseSensitive matches names exactly
+	CaseSensitive CaseFolding = iota
+	// FoldASCII matches names regardless of the case of ASCII letters
+	FoldASCII
+	// FoldUnicode matches names under Unicode simple case folding, like strings.EqualFold
+	FoldUnicode
+)
+
+// fold returns the case folded form of `name`
+func (c CaseFolding) fold(name string) string {
+	switch c {
+	case FoldASCII:
+		return strings.Map(func(r rune) rune {
+			if 'A' <= r && r <= 'Z' {
+				return r + 'a' - 'A'
+			}
+			return r
+		}, name)
+	case FoldUnicode:
+		return strings.Map(foldRune, name)
+	}
+	return name
+}
+
+// foldRune returns the smallest rune that is equivalent to `r` under simple
+// case folding, so equivalent runes fold to the same one
+func foldRune(r rune) rune {
+	folded := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		folded = min(folded, f)
+	}
+	return folded
+}
+
+// LookupOptions configure how sections and keys are looked up by name
+//
+// Names are matched in their normalized form, while the file keeps their
+// original spelling. The zero value matches names exactly and looks up the
+// first occurrence of sections and keys.
+type LookupOptions struct {
+	// DuplicateSections decides how sections with the same name are looked up
+	DuplicateSections DuplicatePolicy
+	// DuplicateKeys decides how keys with the same name in a section are looked up
+	DuplicateKeys DuplicatePolicy
+
+	// DefaultSection names a section whose keys act as fallbacks for keys
+	// missing from every other section, like the `[DEFAULT]` section of
+	// Python's configparser. No section does so if it is empty.
+	DefaultSection string
+
+	// CaseFolding decides whether section and key names match regardless of case
+	CaseFolding CaseFolding
+	// TrimSpace ignores whitespace around the name in between the brackets of
+	// a section header, so `[ name ]` matches "name"
+	TrimSpace bool
+	// NormalizeSection and NormalizeKey are applied to section and key names
+	// after case folding and trimming, if set
+	NormalizeSection func(string) string
+	NormalizeKey     func(string) string
+
+	// Interpolation decides how references to other values are resolved when
+	// values are read through the file, e.g. by GetString or Decode. Values
+	// written through the file, by Set or Encode, are escaped so they read
+	// back as they were written. Values read from a ValueNode or set on a
+	// KeyValueLine are never interpolated nor escaped.
+	Interpolation Interpolation
+}
+
+// Interpolation decides how references to other values are resolved, like the
+// interpolation classes of Python's configparser
+type Interpolation int
+
+const (
+	// NoInterpolation reads values as they are
+	NoInterpolation Interpolation = iota
+	// BasicInterpolation replaces `%(key)s` by the value of the key in the
+	// same section or the default section, and `%%` by `%`
+	BasicInterpolation
+	// ExtendedInterpolation replaces `${key}` by the value of the key in the
+	// same section or the default section, `${section:key}` by the value of
+	// the key in another section, and `$$` by `$`
+	ExtendedInterpolation
+)
+
+// sectionName returns the normalized form of a section name
+func (o LookupOptions) sectionName(name string) string {
+	if o.TrimSpace {
+		name = strings.Trim(name, string(validWhitespaceByteSet))
+	}
+	name = o.CaseFolding.fold(name)
+	if o.NormalizeSection != nil {
+		name = o.NormalizeSection(name)
+	}
+	return name
+}
+
+// keyName returns the normalized form of a key name
+func (o LookupOptions) keyName(name string) string {
+	name = o.CaseFolding.fold(name)
+	if o.NormalizeKey != nil {
+		name = o.NormalizeKey(name)
+	}
+	return name
+}
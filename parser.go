@@ -1,9 +1,12 @@
 package montoya
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 // iniParser state
@@ -11,11 +14,18 @@ type iniParser struct {
 	// file is the IniFile representation being parsed into
 	file *IniFile
 
-	// input is the data source to be consumed
-	input io.Reader
+	// input is the buffered data source to be consumed
+	input *bufio.Reader
 
-	// lineNo and colNo keep track of the current parser position
+	// lineNo and colNo keep track of the current parser position, starting at 1
 	lineNo, colNo int
+	// offset is the offset of the current byte from the start of the input
+	offset int
+	// source holds the raw bytes of the current line read so far, spanning
+	// multiple lines of the input when a value is continued
+	source []byte
+	// physical is the offset in `source` where the current line of the input starts
+	physical int
 	// currentNode is the current node being parsed
 	currentNode IniNode
 	// currentLine and previousLine hold references to the line object in the file
@@ -24,20 +34,156 @@ type iniParser struct {
 	tokenType TokenType
 	// currentByte is the raw byte value of the current token
 	currentByte byte
+	// valueState is the state of the value being parsed, see `nextValueState`
+	valueState int
+
+	// options configure the parser
+	options Options
+	// delimiters holds the bytes that separate a key from its value
+	delimiters byteTable
+	// diagnostics collects the errors recovered from, see `Options.Recover`
+	diagnostics []*ParseError
+
+	// section is the name of the section being parsed
+	section string
+	// seenSections and seenKeys track the names parsed so far, to reject duplicates
+	seenSections map[string]bool
+	seenKeys     map[string]map[string]bool
 }
 
 // Parse consumes the input and returns a parsed IniFile
 func Parse(input io.Reader) (*IniFile, error) {
+	file, _, err := ParseWithOptions(input, Options{})
+	return file, err
+}
+
+// ParseWithOptions consumes the input and returns a parsed IniFile, parsing
+// as configured by `options`
+//
+// With `options.Recover` set, the file is returned together with a diagnostic
+// for every line that failed to parse. An error is then only returned if the
+// input could not be read.
+func ParseWithOptions(input io.Reader, options Options) (*IniFile, []*ParseError, error) {
+	if err := options.validate(); err != nil {
+		return nil, nil, err
+	}
 	parser := &iniParser{
-		input: input,
-		file:  &IniFile{},
+		input:      bufio.NewReader(input),
+		file:       &IniFile{Lookup: options.Lookup, options: options},
+		lineNo:     1,
+		colNo:      1,
+		options:    options,
+		delimiters: newByteTable(options.delimiters()),
+	}
+	file, err := parser.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, parser.diagnostics, nil
+}
+
+// Err returns a ParseError at the current parser position
+func (p *iniParser) Err(code ErrorCode, text string) error {
+	return &ParseError{
+		Line:   p.lineNo,
+		Column: p.colNo,
+		Offset: p.offset,
+		Code:   code,
+		Byte:   p.currentByte,
+		Msg:    text,
+		Source: p.sourceLine(),
+	}
+}
+
+// errAt returns a ParseError for the content at `pos` on the current line,
+// which has been parsed completely
+func (p *iniParser) errAt(pos Position, content []byte, code ErrorCode, text string) *ParseError {
+	lines := bytes.Split(p.source, []byte{B_NEWLINE})
+	err := &ParseError{
+		Line:   pos.Line,
+		Column: pos.Column,
+		Offset: pos.Offset,
+		Code:   code,
+		Msg:    text,
+		Source: bytes.Clone(lines[pos.Line-p.currentLine.Span().Start.Line]),
+	}
+	if len(content) > 0 {
+		err.Byte = content[0]
 	}
-	return parser.parse()
+	return err
+}
+
+// sourceLine returns the complete current line, reading the remainder of it
+// from the input
+//
+// Parsing cannot continue after the remainder has been read. When recovering,
+// the remainder is left to the parser instead and the line is completed by
+// `finishLine`.
+func (p *iniParser) sourceLine() []byte {
+	line := bytes.Clone(p.source[p.physical:])
+	if p.input == nil || p.tokenType == NewLine || p.options.Recover {
+		return line
+	}
+	rest, _ := p.input.ReadBytes(B_NEWLINE)
+	return append(line, bytes.TrimSuffix(rest, []byte{B_NEWLINE})...)
+}
+
+// position returns the current parser position
+func (p *iniParser) position() Position {
+	return Position{Line: p.lineNo, Column: p.colNo, Offset: p.offset}
+}
+
+// nextPosition returns the position of the byte after the current one, on the same line
+func (p *iniParser) nextPosition() Position {
+	return Position{Line: p.lineNo, Column: p.colNo + 1, Offset: p.offset + 1}
+}
+
+// startLine starts a new clear line and node at the current position
+//
+// The new line is started as an `EmptyLine` with a `WhitespaceNode`.
+func (p *iniParser) startLine() {
+	whiteSpace := &WhitespaceNode{span: Span{Start: p.position()}} // we need the concrete type
+	p.currentLine = &EmptyLine{Padding: whiteSpace}
+	p.currentLine.base().span.Start = p.position()
+	p.currentNode = whiteSpace
+}
+
+// isDelimiter checks if the current byte separates a key from its value
+func (p *iniParser) isDelimiter() bool {
+	return p.delimiters[p.currentByte]
+}
+
+// startValue transitions the current line to its value, which starts after
+// the delimiter
+func (p *iniParser) startValue(line *KeyValueLine) {
+	line.delimiter = p.currentByte
+	line.Value = &ValueNode{span: Span{Start: p.nextPosition()}, syntax: line.syntax}
+	p.currentNode = line.Value
+	p.valueState = line.Value.state()
+}
+
+// appendValue appends the current byte to the value being parsed, keeping
+// track of the value state
+func (p *iniParser) appendValue(node *ValueNode) {
+	node.content = append(node.content, p.currentByte)
+	p.valueState = nextValueState(p.valueState, p.currentByte)
 }
 
-// Err returns a parsing error
-func (p *iniParser) Err(text string) error {
-	return fmt.Errorf("%s (line:%v, col:%v)", text, p.lineNo, p.colNo)
+// recover turns the current line into an InvalidLine if the parser recovers
+// from errors, and records `err` as its diagnostic
+//
+// Returns `err` if the parser cannot recover from it.
+func (p *iniParser) recover(err error) error {
+	parseErr, ok := err.(*ParseError)
+	if !ok || !p.options.Recover {
+		return err
+	}
+	p.diagnostics = append(p.diagnostics, parseErr)
+	invalid := &InvalidLine{Err: parseErr}
+	invalid.span = p.currentLine.Span()
+	p.currentLine = invalid
+	p.currentNode = nil
+	return nil
 }
 
 // debug prints out the message together with some parser state
@@ -64,6 +210,7 @@ func (p *iniParser) parseEmptyLine(line *EmptyLine) error {
 			line.Comment = &CommentNode{
 				symbol:  p.currentByte,
 				content: []byte{},
+				span:    Span{Start: p.position()},
 			}
 			p.currentNode = line.Comment
 
@@ -74,25 +221,30 @@ func (p *iniParser) parseEmptyLine(line *EmptyLine) error {
 				Padding: node,
 				Header: &HeaderNode{
 					content: []byte{},
+					span:    Span{Start: p.nextPosition()},
 				},
 			}
+			headerLine.span = line.span
 			p.currentNode = headerLine.Header
 			p.currentLine = headerLine
 		default:
 			// Check if this byte is a valid KeyByte
-			if isKeyByte(p.currentByte) {
+			if isKeyByte(p.currentByte) && !p.isDelimiter() {
 				// Switch current line type to KeyValueLine
 				keyValueLine := &KeyValueLine{
 					// Preserve padding, if any
 					Padding: node,
 					Key: &KeyNode{
 						content: []byte{p.currentByte},
+						span:    Span{Start: p.position()},
 					},
+					syntax: p.options.valueSyntax(),
 				}
+				keyValueLine.span = line.span
 				p.currentLine = keyValueLine
 				p.currentNode = keyValueLine.Key
 			} else {
-				return p.Err(fmt.Sprintf("invalid character %02x for empty line", p.currentByte))
+				return p.Err(ErrInvalidLineStart, fmt.Sprintf("invalid character %02x for empty line", p.currentByte))
 			}
 		}
 	case *CommentNode:
@@ -109,34 +261,47 @@ func (p *iniParser) parseEmptyLine(line *EmptyLine) error {
 // A KeyValueLine looks like this:
 // <Whitespace (optional)><Key>[=]<Value><Comment (optional>[\n]
 //
+// The key and value may be separated by any of the configured delimiters
+// instead of `=`.
+//
 // Since the Parser prioritizes an EmptyLine first, initial Padding is never parsed here
 func (p *iniParser) parseKeyValueLine(line *KeyValueLine) error {
 	switch node := p.currentNode.(type) {
 	case *KeyNode:
-		if p.tokenType == Equals {
-			// Transition to value
-			line.Value = &ValueNode{}
-			p.currentNode = line.Value
+		if p.isDelimiter() {
+			p.startValue(line)
 			break
 		}
 		if p.tokenType == Whitespace {
 			// Transition to PostKeyPad
-			line.PostKeyPad = &WhitespaceNode{content: []byte{p.currentByte}}
+			line.PostKeyPad = &WhitespaceNode{
+				content: []byte{p.currentByte},
+				span:    Span{Start: p.position()},
+			}
 			p.currentNode = line.PostKeyPad
 			break
 		}
+		if p.tokenType == SectionStart && p.options.LocaleKeys {
+			// Transition to the locale
+			line.Locale = &LocaleNode{
+				content: []byte{p.currentByte},
+				span:    Span{Start: p.position()},
+			}
+			p.currentNode = line.Locale
+			break
+		}
 		if isKeyByte(p.currentByte) {
 			// Grow key
 			node.content = append(node.content, p.currentByte)
 		} else {
-			return p.Err(fmt.Sprintf("invalid character %02x in key", p.currentByte))
+			return p.Err(ErrInvalidKey, fmt.Sprintf("invalid character %02x in key", p.currentByte))
 		}
+	case *LocaleNode:
+		return p.parseLocale(line, node)
 	// This must be post-key whitespace
 	case *WhitespaceNode:
-		if p.tokenType == Equals {
-			// Transition to value
-			line.Value = &ValueNode{}
-			p.currentNode = line.Value
+		if p.isDelimiter() {
+			p.startValue(line)
 			break
 		}
 		if p.tokenType == Whitespace {
@@ -144,20 +309,34 @@ func (p *iniParser) parseKeyValueLine(line *KeyValueLine) error {
 			node.content = append(node.content, p.currentByte)
 			break
 		}
-		return p.Err(fmt.Sprintf("invalid non-whitespace character %02x in key", p.currentByte))
+		if p.tokenType == CommentStart && p.options.BareKeys {
+			// A bare key followed by a comment
+			line.Comment = &CommentNode{
+				symbol:  p.currentByte,
+				content: []byte{},
+				span:    Span{Start: p.position()},
+			}
+			p.currentNode = line.Comment
+			break
+		}
+		return p.Err(ErrInvalidAfterKey, fmt.Sprintf("invalid non-whitespace character %02x in key", p.currentByte))
 
 	case *ValueNode:
+		if node.syntax != standardValue {
+			return p.parseFreeformValue(line, node)
+		}
 		// If we encounter a comment symbol transfer to comment node
 		if p.tokenType == CommentStart {
-			// Check if we are in a quoted string
-			if inQuotedString(node.content) {
+			// Check if we are in a quoted string, or the symbol is part of the value
+			if inQuotedString(p.valueState) || !p.startsInlineComment(node) {
 				// simply append
-				node.content = append(node.content, p.currentByte)
+				p.appendValue(node)
 			} else {
 				// Start a new comment
 				line.Comment = &CommentNode{
 					symbol:  p.currentByte,
 					content: []byte{},
+					span:    Span{Start: p.position()},
 				}
 				p.currentNode = line.Comment
 			}
@@ -165,25 +344,28 @@ func (p *iniParser) parseKeyValueLine(line *KeyValueLine) error {
 		}
 		if p.tokenType == Quote {
 			// Check if adding an extra quote is legal
-			if isExtraQuoteLegal(node.content) {
-				node.content = append(node.content, p.currentByte)
+			if isExtraQuoteLegal(p.valueState) {
+				p.appendValue(node)
+			} else if p.opensTripleQuote(node) {
+				p.appendValue(node)
+				p.valueState = VALUE_PARSE_TRIPLE_QUOTED
 			} else {
-				return p.Err("illegal quote character in value")
+				return p.Err(ErrIllegalQuote, "illegal quote character in value")
 			}
 			break
 		}
 		if p.tokenType == Whitespace {
-			node.content = append(node.content, p.currentByte)
+			p.appendValue(node)
 			break
 		}
 		if p.currentByte != B_NULL {
-			if isClosedQuotedString(node.content) {
-				return p.Err(fmt.Sprintf("illegal character %02x after terminated quoted value", p.currentByte))
+			if isClosedQuotedString(p.valueState) {
+				return p.Err(ErrTrailingValue, fmt.Sprintf("illegal character %02x after terminated quoted value", p.currentByte))
 			}
-			node.content = append(node.content, p.currentByte)
+			p.appendValue(node)
 			break
 		} else {
-			return p.Err(fmt.Sprintf("illegal character %02x in value", p.currentByte))
+			return p.Err(ErrInvalidValue, fmt.Sprintf("illegal character %02x in value", p.currentByte))
 		}
 	case *CommentNode:
 		// Anything goes in a comment ;)
@@ -193,6 +375,167 @@ func (p *iniParser) parseKeyValueLine(line *KeyValueLine) error {
 	return nil
 }
 
+// parseLocale parses the current token into the locale after a key, see
+// `Options.LocaleKeys`
+//
+// A closed locale must be followed by whitespace or a delimiter, like a key.
+func (p *iniParser) parseLocale(line *KeyValueLine, node *LocaleNode) error {
+	if !node.closed() {
+		switch {
+		case p.tokenType == SectionEnd && len(node.content) > 1:
+			node.content = append(node.content, p.currentByte)
+		case isLocaleByte(p.currentByte):
+			node.content = append(node.content, p.currentByte)
+		default:
+			return p.Err(ErrInvalidLocale, fmt.Sprintf("invalid character %02x in locale", p.currentByte))
+		}
+		return nil
+	}
+	if p.isDelimiter() {
+		p.startValue(line)
+		return nil
+	}
+	if p.tokenType == Whitespace {
+		// Transition to PostKeyPad
+		line.PostKeyPad = &WhitespaceNode{
+			content: []byte{p.currentByte},
+			span:    Span{Start: p.position()},
+		}
+		p.currentNode = line.PostKeyPad
+		return nil
+	}
+	return p.Err(ErrInvalidLocale, fmt.Sprintf("invalid character %02x after locale", p.currentByte))
+}
+
+// parseFreeformValue parses the current token into a value with embedded
+// quotes or a raw value, see `Options.EmbeddedQuotes` and `Options.RawValues`
+func (p *iniParser) parseFreeformValue(line *KeyValueLine, node *ValueNode) error {
+	// Only a comment symbol outside of quotes and not escaped may start a comment
+	outside := p.valueState == VALUE_PARSE_EMBEDDED || p.valueState == VALUE_PARSE_RAW
+	if p.tokenType == CommentStart && outside && p.startsInlineComment(node) {
+		line.Comment = &CommentNode{
+			symbol:  p.currentByte,
+			content: []byte{},
+			span:    Span{Start: p.position()},
+		}
+		p.currentNode = line.Comment
+		return nil
+	}
+	if nextValueState(p.valueState, p.currentByte) == VALUE_PARSE_ERROR {
+		return p.Err(ErrInvalidValue, fmt.Sprintf("illegal character %02x in value", p.currentByte))
+	}
+	p.appendValue(node)
+	return nil
+}
+
+// startsInlineComment checks if the current comment symbol, found outside of
+// quotes, starts a comment after the value in `node`
+//
+// After a closed quoted value a comment symbol always starts a comment, as
+// nothing else may follow it.
+func (p *iniParser) startsInlineComment(node *ValueNode) bool {
+	if isClosedQuotedString(p.valueState) {
+		return true
+	}
+	switch p.options.InlineComments {
+	case InlineCommentsNever:
+		return false
+	case InlineCommentsAfterWhitespace:
+		n := len(node.content)
+		return n > 0 && isWhitespaceByte(node.content[n-1])
+	}
+	return true
+}
+
+// opensTripleQuote checks if the current quote turns the empty quoted string
+// in `node` into an open triple quoted string
+func (p *iniParser) opensTripleQuote(node *ValueNode) bool {
+	if !p.options.TripleQuotes || p.valueState != VALUE_PARSE_QUOTED_TERMINATED {
+		return false
+	}
+	return bytes.Equal(bytes.TrimLeft(node.content, string(validWhitespaceByteSet)), tripleQuote[:2])
+}
+
+// continuesValue checks if the value on the current line continues on the
+// next line of the input, see `Options.BackslashContinuation`,
+// `Options.IndentedContinuation` and `Options.TripleQuotes`
+//
+// A value followed by a comment does not continue.
+func (p *iniParser) continuesValue() bool {
+	line, ok := p.currentLine.(*KeyValueLine)
+	if !ok || line.Value == nil || p.currentNode != line.Value {
+		return false
+	}
+	if inTripleQuotedString(p.valueState) {
+		// only reachable with triple quotes enabled
+		return true
+	}
+	if p.options.BackslashContinuation && endsInContinuation(line.Value) {
+		return true
+	}
+	if !p.options.IndentedContinuation {
+		return false
+	}
+	switch p.valueState {
+	case VALUE_PARSE_WHITESPACE, VALUE_PARSE_UNQUOTED, VALUE_PARSE_RAW:
+		return p.peekIndentedContent(len(line.Padding.Content()))
+	}
+	return false
+}
+
+// endsInContinuation checks if the value ends in a backslash that continues
+// it on the next line
+func endsInContinuation(value *ValueNode) bool {
+	content := bytes.TrimSuffix(value.content, []byte{B_CR})
+	if !bytes.HasSuffix(content, []byte{B_BACKSLASH}) {
+		return false
+	}
+	if value.syntax == rawValue {
+		return endsInRawContinuation(content)
+	}
+	// an escaped backslash in a quoted value does not count
+	switch contentState(content, value.syntax) {
+	case VALUE_PARSE_UNQUOTED, VALUE_PARSE_QUOTED_BACKSLASH, VALUE_PARSE_EMBEDDED_BACKSLASH, VALUE_PARSE_EMBEDDED_QUOTED_BACKSLASH, VALUE_PARSE_RAW:
+		return true
+	}
+	return false
+}
+
+// peekIndentedContent checks if the next line of the input is indented by
+// more than `indent` whitespace bytes, and neither blank nor a comment
+//
+// Like configparser, a line indented as deep as the key starts a new key
+// instead, so keys may be indented under their section header.
+func (p *iniParser) peekIndentedContent(indent int) bool {
+	for n := 1; ; n++ {
+		peeked, err := p.input.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch next := peeked[n-1]; {
+		case next == B_SPACE || next == B_TAB:
+			continue
+		case n-1 <= indent || next == B_NEWLINE || next == B_CR || convertToken(next) == CommentStart:
+			return false
+		}
+		return true
+	}
+}
+
+// continueValue continues the value on the current line on the next line of the input
+func (p *iniParser) continueValue() {
+	value := p.currentLine.(*KeyValueLine).Value
+	value.content = append(value.content, B_NEWLINE)
+	p.valueState = value.state()
+	p.source = append(p.source, B_NEWLINE)
+
+	// Track position
+	p.lineNo += 1
+	p.colNo = 1
+	p.offset += 1
+	p.physical = len(p.source)
+}
+
 // parseSectionHeaderLine expects to parse current token into a SectionHeaderLine object
 //
 // A SectionHeader looks like this:
@@ -206,17 +549,41 @@ func (p *iniParser) parseSectionHeaderLine(line *SectionHeaderLine) error {
 		// Anything goes in a comment ;)
 		node.content = append(node.content, p.currentByte)
 	case *HeaderNode:
-		switch p.tokenType {
-		case SectionEnd:
+		if p.tokenType == Quote && p.options.Subsections {
+			return p.startSubsection(line)
+		}
+		switch {
+		case p.tokenType == SectionEnd:
 			// Transition to PostPad
-			line.PostPad = &WhitespaceNode{}
+			line.PostPad = &WhitespaceNode{span: Span{Start: p.nextPosition()}}
 			p.currentNode = line.PostPad
-		case CommentStart:
-			return p.Err("illegal comment start in bracket")
+		case p.options.StrictSectionNames:
+			// strict names decide on their own bytes, comment symbols included
+			if !strictSectionByteTable[p.currentByte] {
+				return p.Err(ErrInvalidSectionName, fmt.Sprintf("invalid character %02x in section name", p.currentByte))
+			}
+			node.content = append(node.content, p.currentByte)
+		case p.tokenType == CommentStart:
+			return p.Err(ErrCommentInSection, "illegal comment start in bracket")
 		default:
 			// Grow the header content
 			node.content = append(node.content, p.currentByte)
 		}
+	case *SubsectionNode:
+		if node.closed() {
+			if p.tokenType != SectionEnd {
+				return p.Err(ErrInvalidSubsection, fmt.Sprintf("illegal character %02x after subsection", p.currentByte))
+			}
+			// Transition to PostPad
+			line.PostPad = &WhitespaceNode{span: Span{Start: p.nextPosition()}}
+			p.currentNode = line.PostPad
+			break
+		}
+		if p.currentByte == B_NULL {
+			return p.Err(ErrInvalidSubsection, "illegal null character in subsection")
+		}
+		// Anything else goes in between the quotes
+		node.content = append(node.content, p.currentByte)
 	case *WhitespaceNode:
 		// This must be the PostPad
 		switch p.tokenType {
@@ -225,6 +592,7 @@ func (p *iniParser) parseSectionHeaderLine(line *SectionHeaderLine) error {
 			line.Comment = &CommentNode{
 				symbol:  p.currentByte,
 				content: []byte{},
+				span:    Span{Start: p.position()},
 			}
 			p.currentNode = line.Comment
 
@@ -232,26 +600,40 @@ func (p *iniParser) parseSectionHeaderLine(line *SectionHeaderLine) error {
 			// Grow
 			node.content = append(node.content, p.currentByte)
 		default:
-			return p.Err(fmt.Sprintf("illegal non-comment character %02x after closed section header", p.currentByte))
+			return p.Err(ErrTrailingSection, fmt.Sprintf("illegal non-comment character %02x after closed section header", p.currentByte))
 		}
 
 	}
 	return nil
 }
 
-// advanceLine advances the parser to the next line
+// startSubsection transitions the current line to its subsection at an
+// opening quote, see `Options.Subsections`
 //
-// The line is linked up to the previous line if it exists to create a linked list
-// The new line is started as an `EmptyLine“ with a `WhitespaceNode`
-func (p *iniParser) advanceLine() error {
-
-	if p.currentLine == nil {
-		return errors.New("parser cannot advance on a non-nil line")
+// The whitespace in between the section name and the quote is moved out of
+// the header into its own node.
+func (p *iniParser) startSubsection(line *SectionHeaderLine) error {
+	header := line.Header.content
+	name := bytes.TrimRight(header, string(validWhitespaceByteSet))
+	if len(name) == 0 || len(name) == len(header) {
+		return p.Err(ErrInvalidSubsection, "subsection must follow the section name and whitespace")
+	}
+	line.Header.content = name
+	line.SubsectionPad = &WhitespaceNode{
+		content: bytes.Clone(header[len(name):]),
+		span:    Span{Start: line.Header.span.Start.advance(name)},
 	}
-	if !p.currentLine.Terminated() {
-		return errors.New("the current line was not properly terminated")
+	line.Subsection = &SubsectionNode{
+		content: []byte{B_QUOTE},
+		span:    Span{Start: p.position()},
 	}
+	p.currentNode = line.Subsection
+	return nil
+}
 
+// linkLine links the current line up to the previous line if it exists to
+// create a linked list
+func (p *iniParser) linkLine() {
 	if p.previousLine != nil {
 		p.previousLine.SetNext(p.currentLine)
 		p.currentLine.SetPrev(p.previousLine)
@@ -262,47 +644,236 @@ func (p *iniParser) advanceLine() error {
 
 	// move currentLine cursor
 	p.previousLine = p.currentLine
+}
+
+// finishLine validates the current line and links it into the file
+//
+// `newline` records whether the line was terminated by a newline, or by the
+// end of the input.
+func (p *iniParser) finishLine(newline bool) error {
+	if p.currentLine == nil {
+		return errors.New("parser cannot finish a nil line")
+	}
+	if !p.currentLine.Terminated() && !p.isBareKey() {
+		if err := p.recover(p.terminationErr()); err != nil {
+			return err
+		}
+	}
+	if invalid, ok := p.currentLine.(*InvalidLine); ok {
+		// the rest of the line was skipped, complete it from the source
+		invalid.Raw = bytes.Clone(p.source)
+		invalid.Err.Source = bytes.Clone(p.source[p.physical:])
+	}
+	if err := p.checkDuplicate(); err != nil {
+		// a duplicate parses fine, so the line is kept when recovering
+		if !p.options.Recover {
+			return err
+		}
+		p.diagnostics = append(p.diagnostics, err)
+	}
+
+	p.currentLine.base().newline = newline
+	p.currentLine.base().span.End = p.position()
+	endSpans(p.currentLine)
+	p.linkLine()
+	return nil
+}
 
-	// Start a new clear line and node
-	p.currentLine = &EmptyLine{}
-	p.currentNode = &WhitespaceNode{}
+// endSpans sets the end of the span of every node on the line, following
+// from the start and content of the node
+func endSpans(line IniLine) {
+	switch concrete := line.(type) {
+	case *EmptyLine:
+		endSpan(&concrete.Padding.span, concrete.Padding.Content())
+		if concrete.Comment != nil {
+			endSpan(&concrete.Comment.span, concrete.Comment.Raw())
+		}
+	case *SectionHeaderLine:
+		endSpan(&concrete.Padding.span, concrete.Padding.Content())
+		endSpan(&concrete.Header.span, concrete.Header.Content())
+		if concrete.Subsection != nil {
+			endSpan(&concrete.SubsectionPad.span, concrete.SubsectionPad.Content())
+			endSpan(&concrete.Subsection.span, concrete.Subsection.Content())
+		}
+		if concrete.PostPad != nil {
+			endSpan(&concrete.PostPad.span, concrete.PostPad.Content())
+		}
+		if concrete.Comment != nil {
+			endSpan(&concrete.Comment.span, concrete.Comment.Raw())
+		}
+	case *KeyValueLine:
+		endSpan(&concrete.Padding.span, concrete.Padding.Content())
+		endSpan(&concrete.Key.span, concrete.Key.Content())
+		if concrete.Locale != nil {
+			endSpan(&concrete.Locale.span, concrete.Locale.Content())
+		}
+		if concrete.PostKeyPad != nil {
+			endSpan(&concrete.PostKeyPad.span, concrete.PostKeyPad.Content())
+		}
+		if concrete.Value != nil {
+			endSpan(&concrete.Value.span, concrete.Value.Content())
+		}
+		if concrete.Comment != nil {
+			endSpan(&concrete.Comment.span, concrete.Comment.Raw())
+		}
+	}
+}
+
+// endSpan sets the end of `span` to directly after `content`
+func endSpan(span *Span, content []byte) {
+	span.End = span.Start.advance(content)
+}
+
+// checkDuplicate checks if the current line repeats a section or key seen
+// before, when the duplicate policy rejects that
+func (p *iniParser) checkDuplicate() *ParseError {
+	lookup := p.options.Lookup
+	if lookup.DuplicateSections != RejectDuplicates && lookup.DuplicateKeys != RejectDuplicates {
+		return nil
+	}
+	if p.seenSections == nil {
+		p.seenSections = make(map[string]bool)
+		p.seenKeys = make(map[string]map[string]bool)
+	}
+
+	switch line := p.currentLine.(type) {
+	case *SectionHeaderLine:
+		name := lookup.sectionName(line.Name())
+		seen := p.seenSections[name]
+		p.seenSections[name] = true
+		p.section = name
+		if !seen || lookup.DuplicateSections != MergeDuplicates {
+			// keys of a merged section carry over to its next occurrence
+			p.seenKeys[name] = make(map[string]bool)
+		}
+		if seen && lookup.DuplicateSections == RejectDuplicates {
+			return p.errAt(line.Header.Span().Start, line.Header.Content(), ErrDuplicateSection,
+				fmt.Sprintf("duplicate section %q", line.Name()))
+		}
+	case *KeyValueLine:
+		keys := p.seenKeys[p.section]
+		if keys == nil {
+			keys = make(map[string]bool)
+			p.seenKeys[p.section] = keys
+		}
+		name := lookup.keyName(line.Name())
+		seen := keys[name]
+		keys[name] = true
+		if seen && lookup.DuplicateKeys == RejectDuplicates {
+			return p.errAt(line.Key.Span().Start, line.Key.Content(), ErrDuplicateKey,
+				fmt.Sprintf("duplicate key %q in section %q", line.Name(), p.section))
+		}
+	}
+	return nil
+}
+
+// delimiterNames lists the delimiters for use in error messages, e.g. "`=` or `:`"
+func (p *iniParser) delimiterNames() string {
+	var names []string
+	for _, delimiter := range p.options.delimiters() {
+		names = append(names, "`"+string(delimiter)+"`")
+	}
+	return strings.Join(names, " or ")
+}
+
+// isBareKey checks if the current line is a key without a value, and bare keys
+// are allowed, see `Options.BareKeys`
+func (p *iniParser) isBareKey() bool {
+	line, ok := p.currentLine.(*KeyValueLine)
+	return ok && line.Value == nil && p.options.BareKeys && (line.Locale == nil || line.Locale.closed())
+}
+
+// terminationErr returns the ParseError for a current line that was not
+// properly terminated
+func (p *iniParser) terminationErr() error {
+	switch line := p.currentLine.(type) {
+	case *SectionHeaderLine:
+		return p.Err(ErrUnterminatedSection, "section header was not properly terminated")
+	case *KeyValueLine:
+		if line.Locale != nil && !line.Locale.closed() {
+			return p.Err(ErrInvalidLocale, "locale was not properly terminated")
+		}
+		if line.Value == nil {
+			return p.Err(ErrMissingEquals, "key was not properly terminated, missing "+p.delimiterNames())
+		}
+		if line.Value.state() == VALUE_PARSE_ERROR {
+			return p.Err(ErrInvalidValue, "value was not properly terminated, it contains illegal characters")
+		}
+		return p.Err(ErrUnterminatedValue, "quoted value was not properly terminated")
+	}
+	panic("invalid line type")
+}
+
+// advanceLine advances the parser to the next line
+//
+// The line is linked up to the previous line if it exists to create a linked list
+// The new line is started as an `EmptyLine“ with a `WhitespaceNode`
+func (p *iniParser) advanceLine() error {
+	if err := p.finishLine(true); err != nil {
+		return err
+	}
 
 	// Track position
 	p.lineNo += 1
-	p.colNo = 0
+	p.colNo = 1
+	p.offset += 1
+	p.source = p.source[:0]
+	p.physical = 0
+
+	p.startLine()
 
 	return nil
 }
 
+// stripBOM consumes a leading UTF-8 byte order mark from the input
+func (p *iniParser) stripBOM() error {
+	prefix, err := p.input.Peek(len(utf8BOM))
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if !bytes.Equal(prefix, utf8BOM) {
+		return nil
+	}
+	p.file.BOM = true
+	p.offset = len(utf8BOM)
+	_, err = p.input.Discard(len(utf8BOM))
+	return err
+}
+
 // parse consumes an io.Reader into a parsed IniFile
 func (p *iniParser) parse() (*IniFile, error) {
-	// TODO a line without a newline but EOF is not currently terminated
-
-	whiteSpace := &WhitespaceNode{} // we need the concrete type
-	p.currentNode = whiteSpace
-	p.currentLine = &EmptyLine{Padding: whiteSpace}
+	if err := p.stripBOM(); err != nil {
+		return nil, err
+	}
+	p.startLine()
 
-	buf := make([]byte, 1) // slice of length 1
 	for {
-		_, err := p.input.Read(buf)
+		currentByte, err := p.input.ReadByte()
 		if err == io.EOF {
+			p.currentByte = B_NULL
+			p.tokenType = Other
 			break
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to read input: %w", err)
 		}
 
-		p.currentByte = buf[0]
+		p.currentByte = currentByte
 		p.tokenType = convertToken(p.currentByte)
 
 		// Finish up current line and link up lines
 		if p.tokenType == NewLine {
+			if p.continuesValue() {
+				p.continueValue()
+				continue
+			}
 			err := p.advanceLine()
 			if err != nil {
-				return nil, fmt.Errorf("cannot advance to next line: %w", err)
+				return nil, err
 			}
 			continue
 		}
+		p.source = append(p.source, p.currentByte)
 
 		switch line := p.currentLine.(type) {
 		case *EmptyLine:
@@ -313,18 +884,30 @@ func (p *iniParser) parse() (*IniFile, error) {
 
 		case *SectionHeaderLine:
 			err = p.parseSectionHeaderLine(line)
+
+		case *InvalidLine:
+			// skip the rest of the line
 		default:
 			panic("invalid line type")
 		}
 		if err != nil {
-			return nil, err
+			if err = p.recover(err); err != nil {
+				return nil, err
+			}
 		}
 
 		// Track position
 		p.colNo += 1
+		p.offset += 1
 	}
 
-	p.file.Tail = p.currentLine
+	// The end of the input terminates a final line without a newline
+	if len(p.source) > 0 {
+		if err := p.finishLine(false); err != nil {
+			return nil, err
+		}
+	}
+	p.file.Tail = p.previousLine
 
 	return p.file, nil
 }
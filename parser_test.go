@@ -2,6 +2,7 @@ package montoya
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -49,7 +50,7 @@ func TestParseErrFormat(t *testing.T) {
 		lineNo: 42,
 		colNo:  80,
 	}
-	err := p.Err("test error")
+	err := p.Err(ErrInvalidKey, "test error")
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "test error (line:42, col:80)")
 }
@@ -180,7 +181,9 @@ func TestParseEmptyLineOnlyASingleComment(t *testing.T) {
 
 // Test illegal key byte on an empty line creates an error
 func TestIllegalKeyByteOnEmptyLineIsError(t *testing.T) {
-	lineError := fuzzFromSet(invalidKeyByteSet)
+	// whitespace, newlines and brackets are valid at the start of a line
+	lineError := []byte{fuzzyChoiceExcluding(invalidKeyByteSet, B_SPACE, B_TAB, B_CR, B_NEWLINE, B_BRACKET)}
+	lineError = append(lineError, fuzzFromSet(invalidKeyByteSet)...)
 	file, err := testParse(lineError, B_NEWLINE)
 
 	assert.Error(t, err)
@@ -342,7 +345,8 @@ func TestCommentInHeaderNotAllowed(t *testing.T) {
 func TestNonWhitespaceAfterHeaderNotAllowed(t *testing.T) {
 	section := fuzzSection(false)
 	comment := fuzzComment()
-	illegalByte := fuzzyChoice(invalidWhitespaceByteset)
+	// comments and newlines may legally follow a header
+	illegalByte := fuzzyChoiceExcluding(invalidWhitespaceByteset, B_HASH, B_SEMICOLON, B_NEWLINE)
 	file, err := testParse(B_BRACKET, section, B_BRACKETCLOSE, illegalByte, comment, B_NEWLINE)
 
 	assert.Error(t, err)
@@ -549,7 +553,8 @@ func TestQuotedValue(t *testing.T) {
 // Test an invalid key byte in a key returns an error
 func TestInvalidKeyByteInKeyIsError(t *testing.T) {
 	key := fuzzKey()
-	invalidByte := fuzzyChoice(invalidKeyByteSet)
+	// whitespace, newlines and equals signs may legally follow a key
+	invalidByte := fuzzyChoiceExcluding(invalidKeyByteSet, B_SPACE, B_TAB, B_CR, B_NEWLINE, B_EQUALS)
 	value := fuzzValue(false)
 	file, err := testParse(key, invalidByte, B_EQUALS, value, B_NEWLINE)
 
@@ -562,7 +567,8 @@ func TestInvalidKeyByteInKeyIsError(t *testing.T) {
 func TestNonWhiteSpaceAfterKeyRaisesError(t *testing.T) {
 	key := fuzzKey()
 	whiteSpace := fuzzWhiteSpace(10)
-	invalidByte := fuzzyChoice(invalidWhitespaceByteset)
+	// newlines and equals signs may legally follow a key
+	invalidByte := fuzzyChoiceExcluding(invalidWhitespaceByteset, B_NEWLINE, B_EQUALS)
 	value := fuzzValue(false)
 	file, err := testParse(key, whiteSpace, invalidByte, B_EQUALS, value, B_NEWLINE)
 
@@ -597,7 +603,8 @@ func TestExtraValueQuoteErrorAfterTerminatedQuoted(t *testing.T) {
 func TestNonWhitespaceAfterTerminatedValueIllegal(t *testing.T) {
 	key := fuzzKey()
 	value := fuzzValue(true) // value with quotes
-	illegalByte := fuzzyChoice(invalidWhitespaceByteset)
+	// comments, quotes, newlines and nulls are handled separately
+	illegalByte := fuzzyChoiceExcluding(invalidWhitespaceByteset, B_HASH, B_SEMICOLON, B_QUOTE, B_NEWLINE, B_NULL)
 	file, err := testParse(key, B_EQUALS, value, illegalByte, B_NEWLINE)
 
 	assert.Error(t, err)
@@ -628,3 +635,370 @@ func TestNullByteInRuotedValueIllegal(t *testing.T) {
 	assert.Nil(t, file)
 	assert.ErrorContains(t, err, fmt.Sprintf("illegal character %02x in value", illegalByte))
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// RECOVERY CASES //////////////////////////////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Test recovering turns every broken line into an InvalidLine and reports all of them
+func TestParseRecover(t *testing.T) {
+	input := "[a]\r\nkey = a\"b\r\ngood = 1\r\n[open\r\nbare\r\nother = \"x\" y"
+	file, diagnostics, err := ParseWithOptions(bytes.NewReader([]byte(input)), Options{Recover: true})
+	require.NoError(t, err)
+	require.NotNil(t, file)
+
+	require.Len(t, diagnostics, 4)
+	codes := []ErrorCode{ErrIllegalQuote, ErrUnterminatedSection, ErrMissingEquals, ErrTrailingValue}
+	lines := []int{2, 4, 5, 6}
+	for i, diagnostic := range diagnostics {
+		assert.Equal(t, codes[i], diagnostic.Code)
+		assert.Equal(t, lines[i], diagnostic.Line)
+	}
+	assert.Equal(t, "key = a\"b\r", string(diagnostics[0].Source))
+	assert.Equal(t, "other = \"x\" y", string(diagnostics[3].Source))
+
+	// broken lines are kept verbatim
+	invalid, ok := file.Head.Next().(*InvalidLine)
+	require.True(t, ok)
+	assert.Equal(t, "key = a\"b\r", string(invalid.Raw))
+	assert.Same(t, diagnostics[0], invalid.Err)
+	assert.Equal(t, input, readAll(t, file))
+
+	// the lines that did parse are still available
+	assert.Equal(t, "1", file.Get("a", "good").Value.Value())
+	assert.Len(t, file.Sections(), 1)
+}
+
+// Test recovering from a valid file reports nothing, and without recovering the
+// first error is returned
+func TestParseRecoverOptions(t *testing.T) {
+	file, diagnostics, err := ParseWithOptions(bytes.NewReader([]byte("[a]\nkey = 1\n")), Options{Recover: true})
+	require.NoError(t, err)
+	assert.NotNil(t, file)
+	assert.Empty(t, diagnostics)
+
+	file, diagnostics, err = ParseWithOptions(bytes.NewReader([]byte("[a\nkey\n")), Options{})
+	assert.Nil(t, file)
+	assert.Nil(t, diagnostics)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrUnterminatedSection, parseErr.Code)
+}
+
+// Test edits around an InvalidLine keep it intact
+func TestEditAroundInvalidLine(t *testing.T) {
+	file, _, err := ParseWithOptions(bytes.NewReader([]byte("[a]\r\nkey = 1\r\nbroken")), Options{Recover: true})
+	require.NoError(t, err)
+
+	file.InsertAfter(nil, &EmptyLine{Comment: &CommentNode{symbol: B_SEMICOLON}})
+	require.NoError(t, file.Set("a", "other", "2"))
+	file.SetFinalNewline(true)
+	assert.Equal(t, ";\r\n[a]\r\nkey = 1\r\nother = 2\r\nbroken\r\n", readAll(t, file))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// SPAN CASES //////////////////////////////////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// span creates a Span on a single line from columns and offsets
+func span(line, startCol, startOffset, endCol, endOffset int) Span {
+	return Span{
+		Start: Position{Line: line, Column: startCol, Offset: startOffset},
+		End:   Position{Line: line, Column: endCol, Offset: endOffset},
+	}
+}
+
+// Test every line and node records its location in the source
+func TestParseSpans(t *testing.T) {
+	file, err := testParse("\xEF\xBB\xBF [a] ;c\r\nkey= v\n\n")
+	require.NoError(t, err)
+
+	header, ok := file.Head.(*SectionHeaderLine)
+	require.True(t, ok)
+	assert.Equal(t, span(1, 1, 3, 9, 11), header.Span())
+	assert.Equal(t, span(1, 1, 3, 2, 4), header.Padding.Span())
+	assert.Equal(t, span(1, 3, 5, 4, 6), header.Header.Span())
+	assert.Equal(t, span(1, 5, 7, 6, 8), header.PostPad.Span())
+	assert.Equal(t, span(1, 6, 8, 9, 11), header.Comment.Span())
+
+	key, ok := header.Next().(*KeyValueLine)
+	require.True(t, ok)
+	assert.Equal(t, span(2, 1, 12, 7, 18), key.Span())
+	assert.Equal(t, span(2, 1, 12, 1, 12), key.Padding.Span())
+	assert.Equal(t, span(2, 1, 12, 4, 15), key.Key.Span())
+	assert.Equal(t, span(2, 5, 16, 7, 18), key.Value.Span())
+	assert.Equal(t, Span{}, key.PostKeyPad.Span())
+	assert.Equal(t, "2:5", key.Value.Position().String())
+
+	assert.Equal(t, span(3, 1, 19, 1, 19), file.Tail.Span())
+}
+
+// Test a line at the end of the input and an invalid line have spans
+func TestParseSpansRecover(t *testing.T) {
+	file, _, err := ParseWithOptions(bytes.NewReader([]byte("a = 1\nb = \"x\" y")), Options{Recover: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, span(1, 1, 0, 6, 5), file.Head.Span())
+	invalid, ok := file.Tail.(*InvalidLine)
+	require.True(t, ok)
+	assert.Equal(t, span(2, 1, 6, 10, 15), invalid.Span())
+	assert.Equal(t, "2:1-2:10", invalid.Span().String())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CONTINUATION CASES //////////////////////////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// parseWith parses the input string with the given options
+func parseWith(t *testing.T, input string, options Options) *IniFile {
+	t.Helper()
+	file, _, err := ParseWithOptions(bytes.NewReader([]byte(input)), options)
+	require.NoError(t, err, "input: %q", input)
+	assert.Equal(t, input, readAll(t, file))
+	return file
+}
+
+// Test a trailing backslash continues a value on the next line
+func TestParseBackslashContinuation(t *testing.T) {
+	options := Options{BackslashContinuation: true}
+
+	file := parseWith(t, "[a]\nkey = one \\\n  two\nnext = 1\n", options)
+	key := file.Get("a", "key")
+	assert.Equal(t, "one   two", key.Value.Value())
+	assert.Equal(t, 2, key.Span().Start.Line)
+	assert.Equal(t, Position{Line: 3, Column: 6, Offset: 21}, key.Span().End)
+	assert.Equal(t, "1", file.Get("a", "next").Value.Value())
+	assert.Equal(t, 4, file.Get("a", "next").Span().Start.Line)
+
+	file = parseWith(t, "key = \"one \\\n two\"\r\nk = a\\\r\n b\r\n", options)
+	assert.Equal(t, "one  two", file.Get("", "key").Value.Value())
+	assert.Equal(t, "a b", file.Get("", "k").Value.Value())
+
+	// a continuation at the end of the input continues on an empty line
+	file = parseWith(t, "key = a \\\n", options)
+	assert.Equal(t, "a", file.Get("", "key").Value.Value())
+	assert.False(t, file.FinalNewline())
+
+	// an escaped backslash does not continue a quoted value
+	_, _, err := ParseWithOptions(bytes.NewReader([]byte("key = \"a\\\\\nnext = 1\n")), options)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrUnterminatedValue, parseErr.Code)
+
+	// a comment ends the value
+	file = parseWith(t, "key = a ; comment \\\nnext = 1\n", options)
+	assert.Equal(t, "a", file.Get("", "key").Value.Value())
+
+	// without the option the next line stands on its own
+	_, err = testParse("key = a \\\n b\n")
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrMissingEquals, parseErr.Code)
+}
+
+// Test indented lines continue a value
+func TestParseIndentedContinuation(t *testing.T) {
+	options := Options{IndentedContinuation: true}
+
+	file := parseWith(t, "[a]\nkey = one\n  two\n\tthree\r\n\nempty =\n  value\n  ; comment\n", options)
+	assert.Equal(t, "one\ntwo\nthree", file.Get("a", "key").Value.Value())
+	assert.Equal(t, "\nvalue", file.Get("a", "empty").Value.Value())
+	_, ok := file.Tail.(*EmptyLine)
+	assert.True(t, ok)
+
+	// a value followed by a comment, or a quoted value, does not continue
+	file = parseWith(t, "a = 1 ; comment\n  b = 2\nc = \"3\"\n  d = 4\n", options)
+	assert.Equal(t, "1", file.Get("", "a").Value.Value())
+	assert.Len(t, file.Global().Keys(), 4)
+
+	// only lines indented deeper than the key continue its value
+	file = parseWith(t, "[a]\n  host = a\n  port = 8080\n\tlist =\n\t  one\n\t\ttwo\n\tnext = 1\n", options)
+	assert.Equal(t, "a", file.Get("a", "host").Value.Value())
+	assert.Equal(t, "8080", file.Get("a", "port").Value.Value())
+	assert.Equal(t, "\none\ntwo", file.Get("a", "list").Value.Value())
+	assert.Len(t, file.Section("a").Keys(), 4)
+	file = parseWith(t, encodeExample, options)
+	assert.Len(t, file.Section("server").Keys(), 5)
+}
+
+// Test triple quoted values span lines and are taken verbatim
+func TestParseTripleQuotes(t *testing.T) {
+	options := Options{TripleQuotes: true}
+
+	file := parseWith(t, "key = \"\"\"\nline \"one\"\r\n  two ; \\n\n\"\"\" ; comment\nnext = \"\"\"x\"\"\"\n", options)
+	key := file.Get("", "key")
+	assert.Equal(t, "line \"one\"\n  two ; \\n\n", key.Value.Value())
+	assert.Equal(t, " comment", string(key.Comment.Content()))
+	assert.Equal(t, "x", file.Get("", "next").Value.Value())
+
+	_, _, err := ParseWithOptions(bytes.NewReader([]byte("key = \"\"\"open\n")), options)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrUnterminatedValue, parseErr.Code)
+	assert.Equal(t, 2, parseErr.Line)
+
+	_, err = testParse("key = \"\"\"x\"\"\"\n")
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrIllegalQuote, parseErr.Code)
+}
+
+// Test a broken continued line is kept verbatim when recovering
+func TestParseContinuationRecover(t *testing.T) {
+	input := "key = a \\\n b \" c\nnext = 1\n"
+	file, diagnostics, err := ParseWithOptions(bytes.NewReader([]byte(input)), Options{BackslashContinuation: true, Recover: true})
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, 2, diagnostics[0].Line)
+	assert.Equal(t, " b \" c", string(diagnostics[0].Source))
+
+	invalid, ok := file.Head.(*InvalidLine)
+	require.True(t, ok)
+	assert.Equal(t, "key = a \\\n b \" c", string(invalid.Raw))
+	assert.Equal(t, input, readAll(t, file))
+}
+
+// Test setting a continued value replaces all of its lines
+func TestSetContinuedValue(t *testing.T) {
+	file := parseWith(t, "key = a \\\n  b\nnext = 1\n", Options{BackslashContinuation: true})
+	file.Get("", "key").SetValue("c")
+	assert.Equal(t, "key = c\nnext = 1\n", readAll(t, file))
+
+	// a value ending in a backslash is quoted, so it does not continue
+	file.Get("", "key").SetValue(`C:\dir\`)
+	file = parseWith(t, readAll(t, file), Options{BackslashContinuation: true})
+	assert.Equal(t, `C:\dir\`, file.Get("", "key").Value.Value())
+	assert.Equal(t, "1", file.Get("", "next").Value.Value())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// INLINE COMMENT CASES ////////////////////////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Test the inline comment option decides where comments start in unquoted values
+func TestParseInlineComments(t *testing.T) {
+	input := "color = #ff0000\nurl = http://host/a#b ; comment\nlist = a;b;c\nquoted = \"a\";c\n"
+	cases := []struct {
+		mode     InlineComments
+		color    string
+		url      string
+		list     string
+		comments int
+	}{
+		{InlineCommentsAlways, "", "http://host/a", "a", 4},
+		{InlineCommentsNever, "#ff0000", "http://host/a#b ; comment", "a;b;c", 1},
+		{InlineCommentsAfterWhitespace, "", "http://host/a#b", "a;b;c", 3},
+	}
+
+	for _, c := range cases {
+		file := parseWith(t, input, Options{InlineComments: c.mode})
+		assert.Equal(t, c.color, file.Get("", "color").Value.Value(), "mode: %v", c.mode)
+		assert.Equal(t, c.url, file.Get("", "url").Value.Value(), "mode: %v", c.mode)
+		assert.Equal(t, c.list, file.Get("", "list").Value.Value(), "mode: %v", c.mode)
+		assert.Equal(t, "a", file.Get("", "quoted").Value.Value(), "mode: %v", c.mode)
+
+		comments := 0
+		for _, key := range file.Global().Keys() {
+			if key.Comment != nil {
+				comments++
+			}
+		}
+		assert.Equal(t, c.comments, comments, "mode: %v", c.mode)
+	}
+}
+
+// Test any unquoted value without inline comments round trips
+func TestParseUncommentedValues(t *testing.T) {
+	for range 100 {
+		value := append([]byte{'v'}, fuzzFromSet(invertByteSet(invalidValueByteSetUncommented))...)
+		input := append(append([]byte("key="), value...), B_NEWLINE)
+		file := parseWith(t, string(input), Options{InlineComments: InlineCommentsNever})
+		assert.Nil(t, file.Get("", "key").Comment)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// DELIMITER CASES /////////////////////////////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Test configured delimiters separate keys from values and are kept per line
+func TestParseDelimiters(t *testing.T) {
+	options := Options{Delimiters: []byte("=:")}
+
+	file := parseWith(t, "[a]\nkey = 1\nother : 2\nurl: http://host:80/\neq:a=b\n", options)
+	assert.Equal(t, byte(B_EQUALS), file.Get("a", "key").Delimiter())
+	assert.Equal(t, byte(':'), file.Get("a", "other").Delimiter())
+	assert.Equal(t, "2", file.Get("a", "other").Value.Value())
+	assert.Equal(t, "http://host:80/", file.Get("a", "url").Value.Value())
+	assert.Equal(t, "a=b", file.Get("a", "eq").Value.Value())
+
+	// by default a colon is part of the key
+	file = parseWith(t, "a:b = c\n", Options{})
+	assert.Equal(t, "c", file.Get("", "a:b").Value.Value())
+
+	// a delimiter cannot start a line
+	_, _, err := ParseWithOptions(bytesReader(":a\n"), options)
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, ErrInvalidLineStart, parseErr.Code)
+
+	_, _, err = ParseWithOptions(bytesReader("key\n"), options)
+	assert.ErrorContains(t, err, "missing `=` or `:`")
+
+	// only the configured delimiters separate keys from values
+	_, _, err = ParseWithOptions(bytesReader("key=1\n"), Options{Delimiters: []byte(":")})
+	assert.ErrorContains(t, err, "invalid character 3d in key")
+
+	for _, delimiter := range []byte(" #\"[\n") {
+		_, _, err = ParseWithOptions(bytesReader(""), Options{Delimiters: []byte{delimiter}})
+		assert.ErrorContains(t, err, "invalid delimiter", "delimiter: %q", delimiter)
+	}
+}
+
+// Test new lines copy the delimiter of the neighbouring lines
+func TestSetDelimiter(t *testing.T) {
+	options := Options{Delimiters: []byte("=:")}
+
+	file := parseWith(t, "[a]\nx = 1\ny: 2\n[b]\n", options)
+	require.NoError(t, file.Set("a", "new", "3"))
+	require.NoError(t, file.Set("b", "other", "4"))
+	assert.Equal(t, "[a]\nx = 1\ny: 2\nnew: 3\n[b]\nother = 4\n", readAll(t, file))
+	assert.ErrorContains(t, file.Set("a", "k:v", "5"), "invalid key")
+
+	file = parseWith(t, "[a]\n", Options{Delimiters: []byte(":")})
+	require.NoError(t, file.Set("a", "new", "1"))
+	assert.Equal(t, "[a]\nnew : 1\n", readAll(t, file))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// BARE KEY CASES //////////////////////////////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Test bare keys parse without a value and are written back without a delimiter
+func TestParseBareKeys(t *testing.T) {
+	options := Options{BareKeys: true}
+	input := "[mysqld]\r\nskip-networking\r\n  quick  # no value\r\nport = 3306\r\nflag"
+
+	file := parseWith(t, input, options)
+	for _, key := range []string{"skip-networking", "quick", "flag"} {
+		line := file.Get("mysqld", key)
+		require.NotNil(t, line, "key: %q", key)
+		assert.False(t, line.HasValue(), "key: %q", key)
+		assert.Equal(t, "", line.Value.Value(), "key: %q", key)
+	}
+	assert.True(t, file.Get("mysqld", "port").HasValue())
+	assert.Equal(t, " no value\r", string(file.Get("mysqld", "quick").Comment.Content()))
+	assert.Equal(t, span(3, 3, 29, 8, 34), file.Get("mysqld", "quick").Key.Span())
+
+	// without the option a key needs a value
+	_, err := testParse("skip-networking\n")
+	assert.ErrorContains(t, err, "missing `=`")
+	_, _, err = ParseWithOptions(bytesReader("key value\n"), options)
+	assert.ErrorContains(t, err, "invalid non-whitespace character")
+}
+
+// Test setting the value of a bare key adds a delimiter
+func TestSetBareKey(t *testing.T) {
+	file := parseWith(t, "flag\r\nquick  # comment\n", Options{BareKeys: true})
+	file.Get("", "flag").SetValue("on")
+	file.Get("", "quick").SetValue("yes")
+	assert.Equal(t, "flag=on\r\nquick=yes  # comment\n", readAll(t, file))
+}
@@ -0,0 +1,213 @@
+package montoya
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AddSection appends a new, empty section to the end of the file
+//
+// If the sections in the file are separated by empty lines, an empty line is
+// added before the new header as well.
+func (f *IniFile) AddSection(name string) (*Section, error) {
+	if !f.isValidSectionName(name) {
+		return nil, fmt.Errorf("invalid section name %q", name)
+	}
+	if f.Tail != nil && f.separatesSections() && !isBlankLine(f.Tail) {
+		f.InsertBefore(nil, &EmptyLine{Padding: &WhitespaceNode{}})
+	}
+
+	header := newSectionHeaderLine("")
+	f.setHeaderName(header, name)
+	f.InsertBefore(nil, header)
+	return &Section{file: f, Header: header}, nil
+}
+
+// Rename changes the name of the section, leaving the formatting of the header intact
+//
+// Every header of a merged section is renamed. The global section cannot be renamed.
+func (s *Section) Rename(newName string) error {
+	if !s.file.isValidSectionName(newName) {
+		return fmt.Errorf("invalid section name %q", newName)
+	}
+	if slices.Contains(s.headers(), nil) {
+		return errors.New("cannot rename the global section")
+	}
+	for _, header := range s.headers() {
+		s.file.setHeaderName(header, newName)
+		header.Reset()
+	}
+	s.file.Reset()
+	return nil
+}
+
+// Delete removes the section from the file
+//
+// This removes the header, every line up to the next section and the comment
+// block directly above the header.
+func (s *Section) Delete() {
+	for _, line := range s.extent() {
+		s.file.Remove(line)
+	}
+}
+
+// MoveBefore moves the section, including the comment block directly above
+// its header, in front of `other` and its comment block
+//
+// The global section always stays at the start of the file, so moving it or
+// moving a section in front of it does nothing.
+func (s *Section) MoveBefore(other *Section) {
+	if s.Header == other.Header || s.Header == nil || other.Header == nil {
+		return
+	}
+	mark := other.extent()[0]
+	for _, line := range s.extent() {
+		s.file.MoveBefore(line, mark)
+	}
+}
+
+// extent returns all lines belonging to the section in file order
+//
+// A section starts at the comment block directly above its header and ends
+// before the comment block of the next section. A merged section consists of
+// the lines of all its occurrences.
+func (s *Section) extent() (lines []IniLine) {
+	for _, header := range s.headers() {
+		lines = append(lines, s.file.headerExtent(header)...)
+	}
+	return
+}
+
+// headerExtent returns the lines belonging to the section opened by `header`,
+// which is nil for the global section
+func (f *IniFile) headerExtent(header *SectionHeaderLine) (lines []IniLine) {
+	if header != nil {
+		lines = append(commentBlock(header), header)
+	}
+
+	var next IniLine
+	for line := f.afterHeader(header); line != nil; line = line.Next() {
+		if _, ok := line.(*SectionHeaderLine); ok {
+			next = line
+			break
+		}
+	}
+	end := next
+	if block := commentBlock(next); len(block) > 0 {
+		end = block[0]
+	}
+
+	for line := f.afterHeader(header); line != end; line = line.Next() {
+		lines = append(lines, line)
+	}
+	return
+}
+
+// commentBlock returns the comment-only lines directly above `line`, in file order
+func commentBlock(line IniLine) (block []IniLine) {
+	if line == nil {
+		return nil
+	}
+	for prev := line.Previous(); prev != nil; prev = prev.Previous() {
+		empty, ok := prev.(*EmptyLine)
+		if !ok || empty.Comment == nil {
+			break
+		}
+		block = append([]IniLine{prev}, block...)
+	}
+	return
+}
+
+// isBlankLine checks if `line` is an EmptyLine without a comment
+func isBlankLine(line IniLine) bool {
+	empty, ok := line.(*EmptyLine)
+	return ok && empty.Comment == nil
+}
+
+// separatesSections checks if the file puts an empty line before its section
+// headers, judging by the first header that is not at the start of the file
+func (f *IniFile) separatesSections() bool {
+	for _, section := range f.Sections() {
+		block := commentBlock(section.Header)
+		first := IniLine(section.Header)
+		if len(block) > 0 {
+			first = block[0]
+		}
+		if prev := first.Previous(); prev != nil {
+			return isBlankLine(prev)
+		}
+	}
+	return false
+}
+
+// isValidSectionName checks if `name` can be written in between the brackets
+// of a SectionHeaderLine in the file
+//
+// With subsections, the part after the first dot is written as a quoted
+// subsection, which may hold anything but nulls and newlines. Strict section
+// names may only hold printable ASCII other than brackets.
+func (f *IniFile) isValidSectionName(name string) bool {
+	if f.options.StrictSectionNames && !isStrictSectionName(name) {
+		return false
+	}
+	if !f.options.Subsections {
+		return isValidSectionName(name)
+	}
+	section, subsection, _ := strings.Cut(name, ".")
+	return isValidSectionName(section) && strings.IndexByte(section, B_QUOTE) < 0 &&
+		!strings.ContainsAny(subsection, "\x00\n")
+}
+
+// setHeaderName sets the name of the header, splitting off a subsection at
+// the first dot if the file has subsections
+func (f *IniFile) setHeaderName(header *SectionHeaderLine, name string) {
+	section, subsection, found := strings.Cut(name, ".")
+	if !f.options.Subsections || !found {
+		header.Header.content = []byte(name)
+		header.SubsectionPad, header.Subsection = nil, nil
+		return
+	}
+	header.Header.content = []byte(section)
+	if header.SubsectionPad == nil {
+		header.SubsectionPad = &WhitespaceNode{content: []byte{B_SPACE}}
+	}
+	header.Subsection = &SubsectionNode{content: quoteSubsection(subsection)}
+}
+
+// isValidSectionName checks if `name` can be written in between the brackets
+// of a SectionHeaderLine
+func isValidSectionName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isSectionByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isStrictSectionName checks if `name` only holds bytes that strict section
+// names allow, see `Options.StrictSectionNames`
+func isStrictSectionName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !strictSectionByteTable[name[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSectionByte checks if the input may be present in a section name
+func isSectionByte(input byte) bool {
+	return sectionByteTable[input]
+}
+
+// newSectionHeaderLine creates an unterminated SectionHeaderLine for `name`
+func newSectionHeaderLine(name string) *SectionHeaderLine {
+	return &SectionHeaderLine{
+		Padding: &WhitespaceNode{},
+		Header:  &HeaderNode{content: []byte(name)},
+		PostPad: &WhitespaceNode{},
+	}
+}
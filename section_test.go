@@ -0,0 +1,111 @@
+package montoya
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sectionExample = `; file header
+
+# about a
+[a]
+x = 1
+
+; about b
+; more about b
+[b]
+y = 2
+
+[c]
+z = 3
+`
+
+// Test a new section is appended, separated like the existing sections
+func TestAddSection(t *testing.T) {
+	file, err := testParse(sectionExample)
+	require.NoError(t, err)
+
+	section, err := file.AddSection("d")
+	require.NoError(t, err)
+	assert.Equal(t, "d", section.Name())
+	require.NoError(t, file.Set("d", "w", "4"))
+
+	assert.Equal(t, sectionExample+"\n[d]\nw = 4\n", readAll(t, file))
+	assert.Equal(t, "4", file.Get("d", "w").Value.Value())
+}
+
+// Test a section is added without a separator when the file does not use them
+func TestAddSectionCompact(t *testing.T) {
+	file, err := testParse("[a]\r\nx=1")
+	require.NoError(t, err)
+
+	_, err = file.AddSection("b")
+	require.NoError(t, err)
+	assert.Equal(t, "[a]\r\nx=1\r\n[b]", readAll(t, file))
+
+	_, err = file.AddSection("bad]name")
+	assert.Error(t, err)
+
+	empty := &IniFile{}
+	_, err = empty.AddSection("first")
+	require.NoError(t, err)
+	assert.Equal(t, "[first]\n", readAll(t, empty))
+}
+
+// Test renaming keeps the formatting of the header
+func TestRenameSection(t *testing.T) {
+	file, err := testParse("  [old]  ; comment\nkey=1\n")
+	require.NoError(t, err)
+
+	require.NoError(t, file.Section("old").Rename("new"))
+	assert.Equal(t, "  [new]  ; comment\nkey=1\n", readAll(t, file))
+	assert.NotNil(t, file.Get("new", "key"))
+
+	assert.Error(t, file.Section("new").Rename("a;b"))
+}
+
+// Test deleting a section removes its comment block and body
+func TestDeleteSection(t *testing.T) {
+	file, err := testParse(sectionExample)
+	require.NoError(t, err)
+
+	file.Section("b").Delete()
+	assert.Equal(t, "; file header\n\n# about a\n[a]\nx = 1\n\n[c]\nz = 3\n", readAll(t, file))
+
+	file.Section("c").Delete()
+	assert.Equal(t, "; file header\n\n# about a\n[a]\nx = 1\n\n", readAll(t, file))
+	assertLinked(t, file)
+}
+
+// Test moving a section takes its comment block along
+func TestMoveSectionBefore(t *testing.T) {
+	file, err := testParse(sectionExample)
+	require.NoError(t, err)
+
+	file.Section("b").MoveBefore(file.Section("a"))
+	assert.Equal(t, "; file header\n\n; about b\n; more about b\n[b]\ny = 2\n\n# about a\n[a]\nx = 1\n\n[c]\nz = 3\n", readAll(t, file))
+	assertLinked(t, file)
+
+	file.Section("b").MoveBefore(file.Section("b"))
+	assert.Equal(t, []string{"b", "a", "c"}, sectionNames(file))
+}
+
+// Test moving the last section of a file without a final newline
+func TestMoveLastSection(t *testing.T) {
+	file, err := testParse("[a]\nx=1\n[b]\ny=2")
+	require.NoError(t, err)
+
+	file.Section("b").MoveBefore(file.Section("a"))
+	assert.Equal(t, "[b]\ny=2\n[a]\nx=1", readAll(t, file))
+	assertLinked(t, file)
+}
+
+// sectionNames returns the names of all sections in file order
+func sectionNames(file *IniFile) (names []string) {
+	for _, section := range file.Sections() {
+		names = append(names, section.Name())
+	}
+	return
+}
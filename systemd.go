@@ -0,0 +1,138 @@
+package montoya
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Systemd returns the options for parsing systemd unit files, such as
+// `.service`, `.timer` and `.network` files and their drop-ins
+//
+// Values are taken verbatim and continue on the next line after a backslash,
+// unless it is escaped by another backslash. Comments only start at the start
+// of a line. A key may be assigned more than once: Get returns the last value,
+// and GetList returns the list the assignments build up.
+func Systemd() Options {
+	return Options{
+		BackslashContinuation: true,
+		RawValues:             true,
+		InlineComments:        InlineCommentsNever,
+		Lookup: LookupOptions{
+			DuplicateSections: MergeDuplicates,
+			DuplicateKeys:     MergeDuplicates,
+		},
+	}
+}
+
+// GetList returns the values assigned to `key` in `section`, in file order
+//
+// An empty assignment clears the values assigned before it, as systemd does
+// for e.g. `ExecStart=`. Returns nil if the key is missing.
+func (f *IniFile) GetList(section, key string) (values []string) {
+	for _, line := range f.GetAll(section, key) {
+		value := line.Value.Value()
+		if value == "" {
+			values = nil
+			continue
+		}
+		values = append(values, value)
+	}
+	return
+}
+
+// GetSystemdBool returns the value of `key` in `section` as a systemd boolean
+//
+// Accepts 1/0, yes/no, y/n, true/false, t/f and on/off in any case.
+func (f *IniFile) GetSystemdBool(section, key string) (bool, error) {
+	return getTyped(f, section, key, parseSystemdBool)
+}
+
+// GetSystemdBoolDefault returns the value of `key` in `section` as a systemd
+// boolean, or `def` if the key is missing or invalid
+func (f *IniFile) GetSystemdBoolDefault(section, key string, def bool) bool {
+	value, err := f.GetSystemdBool(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetTimeSpan returns the value of `key` in `section` as a systemd time span,
+// such as `1h 30min` or `500ms`
+//
+// A number without a unit is in seconds. `infinity` is the largest duration.
+func (f *IniFile) GetTimeSpan(section, key string) (time.Duration, error) {
+	return getTyped(f, section, key, parseTimeSpan)
+}
+
+// GetTimeSpanDefault returns the value of `key` in `section` as a systemd time
+// span, or `def` if the key is missing or invalid
+func (f *IniFile) GetTimeSpanDefault(section, key string, def time.Duration) time.Duration {
+	value, err := f.GetTimeSpan(section, key)
+	return orDefault(value, err, def)
+}
+
+// parseSystemdBool parses the spellings of a boolean systemd accepts, case-insensitively
+func parseSystemdBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true, nil
+	case "0", "no", "n", "false", "f", "off":
+		return false, nil
+	}
+	return false, errors.New("not a boolean")
+}
+
+// timeSpanUnits holds the length of every unit of a systemd time span
+var timeSpanUnits = map[string]time.Duration{
+	"us": time.Microsecond, "usec": time.Microsecond, "µs": time.Microsecond, "μs": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	"M": 2629800 * time.Second, "month": 2629800 * time.Second, "months": 2629800 * time.Second,
+	"y": 31557600 * time.Second, "year": 31557600 * time.Second, "years": 31557600 * time.Second,
+}
+
+// parseTimeSpan parses a systemd time span, a sequence of numbers that are
+// each followed by an optional unit
+func parseTimeSpan(value string) (time.Duration, error) {
+	whitespace := string(validWhitespaceByteSet)
+	value = strings.Trim(value, whitespace)
+	if value == "infinity" {
+		return math.MaxInt64, nil
+	}
+	if value == "" {
+		return 0, errors.New("empty time span")
+	}
+
+	var total time.Duration
+	for value != "" {
+		n := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if n < 0 {
+			n = len(value)
+		}
+		number, err := strconv.ParseFloat(value[:n], 64)
+		if err != nil {
+			return 0, errors.New("invalid number in time span")
+		}
+		value = strings.TrimLeft(value[n:], whitespace)
+
+		n = strings.IndexFunc(value, func(r rune) bool { return ('0' <= r && r <= '9') || strings.ContainsRune(whitespace, r) })
+		if n < 0 {
+			n = len(value)
+		}
+		unit := time.Second
+		if n > 0 {
+			var ok bool
+			if unit, ok = timeSpanUnits[value[:n]]; !ok {
+				return 0, errors.New("unknown unit in time span")
+			}
+		}
+		total += time.Duration(number * float64(unit))
+		value = strings.TrimLeft(value[n:], whitespace)
+	}
+	return total, nil
+}
@@ -0,0 +1,105 @@
+package montoya
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const systemdExample = `# /etc/systemd/system/app.service.d/override.conf
+[Unit]
+Description=App server # not a comment
+After=network.target
+
+[Service]
+ExecStart=/usr/bin/app --old
+ExecStart=
+ExecStart=/bin/sh -c "echo 'starting' ; exec /usr/bin/app" \
+    --config=/etc/app.conf
+ExecStartPost=/usr/bin/notify
+; a comment
+TimeoutStartSec=1min 30s
+Restart=on-failure
+PrivateTmp=y
+`
+
+// Test a unit file round trips and is looked up the way systemd does
+func TestSystemd(t *testing.T) {
+	file := parseWith(t, systemdExample, Systemd())
+
+	assert.Equal(t, "App server # not a comment", file.Get("Unit", "Description").Value.Value())
+	assert.Equal(t, []string{
+		`/bin/sh -c "echo 'starting' ; exec /usr/bin/app"  --config=/etc/app.conf`,
+	}, file.GetList("Service", "ExecStart"))
+	assert.Len(t, file.GetAll("Service", "ExecStart"), 3)
+	assert.Nil(t, file.GetList("Service", "Missing"))
+
+	timeout, err := file.GetTimeSpan("Service", "TimeoutStartSec")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	private, err := file.GetSystemdBool("Service", "PrivateTmp")
+	require.NoError(t, err)
+	assert.True(t, private)
+	assert.False(t, file.GetSystemdBoolDefault("Service", "Restart", false))
+	assert.Equal(t, time.Minute, file.GetTimeSpanDefault("Service", "Restart", time.Minute))
+}
+
+// Test editing a drop-in keeps its formatting
+func TestSystemdEdit(t *testing.T) {
+	file := parseWith(t, "[Service]\nExecStart=/usr/bin/app\n", Systemd())
+
+	require.NoError(t, file.Add("Service", "ExecStart", ""))
+	require.NoError(t, file.Add("Service", "ExecStart", "/usr/bin/app --debug \"a b\""))
+	require.NoError(t, file.Set("Service", "Environment", "A=1\nB=2"))
+	assert.Equal(t, "[Service]\nExecStart=/usr/bin/app\nExecStart=\nExecStart=/usr/bin/app --debug \"a b\"\nEnvironment=A=1\\\nB=2\n", readAll(t, file))
+	assert.Equal(t, []string{"/usr/bin/app --debug \"a b\""}, file.GetList("Service", "ExecStart"))
+	assert.Equal(t, "A=1 B=2", file.Get("Service", "Environment").Value.Value())
+
+	// a trailing backslash is escaped, so it does not swallow the next line
+	file = parseWith(t, "[Service]\nExecStart=/usr/bin/app\nUser=app\n", Systemd())
+	require.NoError(t, file.Set("Service", "ExecStart", `foo\`))
+	assert.Equal(t, "[Service]\nExecStart=foo\\\\\nUser=app\n", readAll(t, file))
+	file = parseWith(t, readAll(t, file), Systemd())
+	assert.Equal(t, `foo\\`, file.Get("Service", "ExecStart").Value.Value())
+	assert.Equal(t, "app", file.Get("Service", "User").Value.Value())
+	file = parseWith(t, "[Service]\nExecStart=a\\\\\\\n  b\n", Systemd())
+	assert.Equal(t, `a\\ b`, file.Get("Service", "ExecStart").Value.Value())
+}
+
+// Test parsing systemd time spans
+func TestParseTimeSpan(t *testing.T) {
+	cases := map[string]time.Duration{
+		"5":           5 * time.Second,
+		"500ms":       500 * time.Millisecond,
+		"1h30min":     90 * time.Minute,
+		" 2 h 5 m ":   2*time.Hour + 5*time.Minute,
+		"1.5d":        36 * time.Hour,
+		"1w 2days":    9 * 24 * time.Hour,
+		"10µs 3usec":  13 * time.Microsecond,
+		"1y":          31557600 * time.Second,
+		"infinity":    math.MaxInt64,
+		"2 seconds 1": 3 * time.Second,
+	}
+	for input, expected := range cases {
+		d, err := parseTimeSpan(input)
+		require.NoError(t, err, "input: %q", input)
+		assert.Equal(t, expected, d, "input: %q", input)
+	}
+
+	for _, input := range []string{"", "min", "5 parsecs", "1..2s"} {
+		_, err := parseTimeSpan(input)
+		assert.Error(t, err, "input: %q", input)
+	}
+}
+
+// Test raw values cannot be combined with embedded quotes
+func TestSystemdOptions(t *testing.T) {
+	options := Systemd()
+	options.EmbeddedQuotes = true
+	_, _, err := ParseWithOptions(bytesReader(""), options)
+	assert.ErrorContains(t, err, "cannot be combined")
+}
@@ -0,0 +1,51 @@
+#!/usr/bin/env python3
+"""Records how Python's configparser reads every *.ini file in this directory.
+
+For each file, a .json file next to it holds the sections in file order and
+the interpolated value of every key in each section, including the keys of
+the DEFAULT section. A value that configparser cannot interpolate is null.
+
+A file starting with `# interpolation: extended` is read with
+ExtendedInterpolation, every other file with BasicInterpolation.
+
+Run it from this directory after adding or changing a file:
+
+    python3 generate.py
+"""
+
+import configparser
+import glob
+import json
+
+
+def read(path):
+    with open(path, encoding="utf-8") as f:
+        text = f.read()
+    mode = "basic"
+    interpolation = configparser.BasicInterpolation()
+    if text.startswith("# interpolation: extended"):
+        mode = "extended"
+        interpolation = configparser.ExtendedInterpolation()
+
+    parser = configparser.ConfigParser(interpolation=interpolation)
+    parser.read_string(text, source=path)
+
+    sections = parser.sections()
+    values = {}
+    names = sections
+    if parser.defaults():
+        names = [parser.default_section] + sections
+    for section in names:
+        values[section] = {}
+        for key in parser[section]:
+            try:
+                values[section][key] = parser.get(section, key)
+            except configparser.Error:
+                values[section][key] = None
+    return {"interpolation": mode, "sections": sections, "values": values}
+
+
+for path in sorted(glob.glob("*.ini")):
+    with open(path[: -len(".ini")] + ".json", "w", encoding="utf-8") as f:
+        json.dump(read(path), f, indent=2, ensure_ascii=False)
+        f.write("\n")
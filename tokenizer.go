@@ -1,6 +1,7 @@
 package montoya
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 )
@@ -23,8 +24,21 @@ type Token struct {
 	Kind    TokenType
 }
 
-// convert tokensj
+// tokenTable holds the TokenType of every byte value
+var tokenTable = func() (table [256]TokenType) {
+	for i := range table {
+		table[i] = classifyToken(byte(i))
+	}
+	return
+}()
+
+// convertToken returns the TokenType of a raw byte
 func convertToken(rawByte byte) TokenType {
+	return tokenTable[rawByte]
+}
+
+// classifyToken determines the TokenType of a raw byte, used to fill `tokenTable`
+func classifyToken(rawByte byte) TokenType {
 	switch rawByte {
 	case B_NEWLINE:
 		return NewLine
@@ -53,10 +67,9 @@ func convertToken(rawByte byte) TokenType {
 func Tokenize(input io.Reader) ([]Token, error) {
 	var tokens []Token
 
-	buf := make([]byte, 1) // slice of length 1
-
+	reader := bufio.NewReader(input)
 	for {
-		_, err := input.Read(buf)
+		rawByte, err := reader.ReadByte()
 		if err == io.EOF {
 			break
 		}
@@ -64,8 +77,8 @@ func Tokenize(input io.Reader) ([]Token, error) {
 			return nil, fmt.Errorf("failed to read input: %w", err)
 		}
 		token := Token{
-			Content: buf[0],
-			Kind:    convertToken(buf[0]),
+			Content: rawByte,
+			Kind:    convertToken(rawByte),
 		}
 		tokens = append(tokens, token)
 	}
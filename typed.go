@@ -0,0 +1,284 @@
+package montoya
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrKeyNotFound is returned by typed getters when the section or key does not exist
+var ErrKeyNotFound = errors.New("key not found")
+
+// ValueError is returned when a value cannot be converted to the requested type
+type ValueError struct {
+	// Section and Key identify the value
+	Section, Key string
+	// Value is the decoded value that failed to convert
+	Value string
+	// Pos is the position of the value in the source
+	Pos Position
+	// Err is the underlying conversion error
+	Err error
+}
+
+// Error implements the error interface
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid value %q for key %q in section %q: %v (line:%v, col:%v)",
+		e.Value, e.Key, e.Section, e.Err, e.Pos.Line, e.Pos.Column)
+}
+
+// Unwrap returns the underlying conversion error
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// getTyped looks up `key` in `section` and converts its value using `convert`
+//
+// The value is interpolated before it is converted, see `LookupOptions.Interpolation`.
+func getTyped[T any](f *IniFile, section, key string, convert func(string) (T, error)) (result T, err error) {
+	s := f.Section(section)
+	var line *KeyValueLine
+	if s != nil {
+		line = s.Key(key)
+	}
+	if line == nil {
+		return result, fmt.Errorf("%w: %q in section %q", ErrKeyNotFound, key, section)
+	}
+	value, err := s.valueOf(line)
+	if err != nil {
+		return result, err
+	}
+	result, err = convert(value)
+	if err != nil {
+		return result, &ValueError{
+			Section: section,
+			Key:     key,
+			Value:   value,
+			Pos:     line.valuePosition(),
+			Err:     err,
+		}
+	}
+	return result, nil
+}
+
+// orDefault returns `def` if `err` is set, `value` otherwise
+func orDefault[T any](value T, err error, def T) T {
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// parseBool parses the usual INI spellings of a boolean, case-insensitively
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, errors.New("not a boolean")
+}
+
+// parseInt64 parses an integer, see GetInt
+func parseInt64(value string) (int64, error) {
+	return parseInteger(value, 64, strconv.ParseInt)
+}
+
+// parseInt parses an integer, see GetInt
+func parseInt(value string) (int, error) {
+	n, err := parseInteger(value, strconv.IntSize, strconv.ParseInt)
+	return int(n), err
+}
+
+// parseUint parses an unsigned integer, see GetInt
+func parseUint(value string) (uint, error) {
+	n, err := parseInteger(value, strconv.IntSize, strconv.ParseUint)
+	return uint(n), err
+}
+
+// parseUint64 parses an unsigned integer, see GetInt
+func parseUint64(value string) (uint64, error) {
+	return parseInteger(value, 64, strconv.ParseUint)
+}
+
+// parseInteger parses an integer of `bitSize` bits with `parse`, in base 10
+// unless a `0x`, `0o` or `0b` prefix after the sign selects another base
+func parseInteger[T int64 | uint64](value string, bitSize int, parse func(string, int, int) (T, error)) (T, error) {
+	sign, digits, base := "", value, 10
+	if digits != "" && (digits[0] == '+' || digits[0] == '-') {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+	n, err := parse(sign+digits, base, bitSize)
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		// report the value as written, prefix included
+		numErr.Num = value
+	}
+	return n, err
+}
+
+// parseFloat parses a 64-bit floating point number
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
+// splitList splits `value` on `sep`, trimming whitespace around each element
+//
+// An empty value yields an empty list.
+func splitList(value, sep string) []string {
+	if value == "" {
+		return nil
+	}
+	elements := strings.Split(value, sep)
+	for i, element := range elements {
+		elements[i] = strings.Trim(element, string(validWhitespaceByteSet))
+	}
+	return elements
+}
+
+// GetBool returns the value of `key` in `section` as a boolean
+//
+// Accepts 1/0, true/false, yes/no and on/off in any case. A bare key without
+// a value is true, see `Options.BareKeys`.
+func (f *IniFile) GetBool(section, key string) (bool, error) {
+	if line := f.Get(section, key); line != nil && !line.HasValue() {
+		return true, nil
+	}
+	return getTyped(f, section, key, parseBool)
+}
+
+// GetBoolDefault returns the value of `key` in `section` as a boolean, or `def`
+// if the key is missing or invalid
+func (f *IniFile) GetBoolDefault(section, key string, def bool) bool {
+	value, err := f.GetBool(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetInt returns the value of `key` in `section` as an int
+//
+// The value is parsed as a decimal number, leading zeros included, so `010`
+// is 10. A `0x`, `0o` or `0b` prefix selects hexadecimal, octal or binary
+// instead. The other integer getters parse the same way.
+func (f *IniFile) GetInt(section, key string) (int, error) {
+	return getTyped(f, section, key, parseInt)
+}
+
+// GetIntDefault returns the value of `key` in `section` as an int, or `def`
+// if the key is missing or invalid
+func (f *IniFile) GetIntDefault(section, key string, def int) int {
+	value, err := f.GetInt(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetInt64 returns the value of `key` in `section` as an int64
+func (f *IniFile) GetInt64(section, key string) (int64, error) {
+	return getTyped(f, section, key, parseInt64)
+}
+
+// GetInt64Default returns the value of `key` in `section` as an int64, or `def`
+// if the key is missing or invalid
+func (f *IniFile) GetInt64Default(section, key string, def int64) int64 {
+	value, err := f.GetInt64(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetUint returns the value of `key` in `section` as a uint
+func (f *IniFile) GetUint(section, key string) (uint, error) {
+	return getTyped(f, section, key, parseUint)
+}
+
+// GetUintDefault returns the value of `key` in `section` as a uint, or `def`
+// if the key is missing or invalid
+func (f *IniFile) GetUintDefault(section, key string, def uint) uint {
+	value, err := f.GetUint(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetFloat returns the value of `key` in `section` as a float64
+func (f *IniFile) GetFloat(section, key string) (float64, error) {
+	return getTyped(f, section, key, parseFloat)
+}
+
+// GetFloatDefault returns the value of `key` in `section` as a float64, or `def`
+// if the key is missing or invalid
+func (f *IniFile) GetFloatDefault(section, key string, def float64) float64 {
+	value, err := f.GetFloat(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetDuration returns the value of `key` in `section` as a time.Duration
+//
+// The value is parsed by time.ParseDuration, e.g. "1h30m".
+func (f *IniFile) GetDuration(section, key string) (time.Duration, error) {
+	return getTyped(f, section, key, time.ParseDuration)
+}
+
+// GetDurationDefault returns the value of `key` in `section` as a
+// time.Duration, or `def` if the key is missing or invalid
+func (f *IniFile) GetDurationDefault(section, key string, def time.Duration) time.Duration {
+	value, err := f.GetDuration(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetTime returns the value of `key` in `section` as a time.Time, parsed with `layout`
+func (f *IniFile) GetTime(section, key, layout string) (time.Time, error) {
+	return getTyped(f, section, key, func(value string) (time.Time, error) {
+		return time.Parse(layout, value)
+	})
+}
+
+// GetTimeDefault returns the value of `key` in `section` as a time.Time,
+// parsed with `layout`, or `def` if the key is missing or invalid
+func (f *IniFile) GetTimeDefault(section, key, layout string, def time.Time) time.Time {
+	value, err := f.GetTime(section, key, layout)
+	return orDefault(value, err, def)
+}
+
+// GetString returns the value of `key` in `section`
+//
+// Unlike the value of the KeyValueLine, the value is interpolated, see
+// `LookupOptions.Interpolation`.
+func (f *IniFile) GetString(section, key string) (string, error) {
+	return getTyped(f, section, key, func(value string) (string, error) {
+		return value, nil
+	})
+}
+
+// GetStringDefault returns the value of `key` in `section`, or `def` if the
+// key is missing or cannot be interpolated
+func (f *IniFile) GetStringDefault(section, key, def string) string {
+	value, err := f.GetString(section, key)
+	return orDefault(value, err, def)
+}
+
+// GetStringSlice returns the value of `key` in `section` split on `sep`
+//
+// Whitespace around each element is trimmed.
+func (f *IniFile) GetStringSlice(section, key, sep string) ([]string, error) {
+	return getTyped(f, section, key, func(value string) ([]string, error) {
+		return splitList(value, sep), nil
+	})
+}
+
+// GetStringSliceDefault returns the value of `key` in `section` split on
+// `sep`, or `def` if the key is missing
+func (f *IniFile) GetStringSliceDefault(section, key, sep string, def []string) []string {
+	value, err := f.GetStringSlice(section, key, sep)
+	return orDefault(value, err, def)
+}
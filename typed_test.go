@@ -0,0 +1,154 @@
+package montoya
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const typedExample = `[types]
+yes = Yes
+off = off
+one = 1
+int = -42
+hex = 0x1F
+big = 9000000000
+float = 3.25
+duration = 1h30m
+time = 2024-02-29T12:00:00Z
+list = a, b ,c
+empty =
+bad = "not a number"
+`
+
+// Test booleans accept the usual INI spellings
+func TestGetBool(t *testing.T) {
+	file, err := testParse(typedExample)
+	require.NoError(t, err)
+
+	for key, expected := range map[string]bool{"yes": true, "off": false, "one": true} {
+		value, err := file.GetBool("types", key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, value, key)
+	}
+
+	_, err = file.GetBool("types", "int")
+	assert.Error(t, err)
+	assert.True(t, file.GetBoolDefault("types", "missing", true))
+	assert.True(t, file.GetBoolDefault("types", "bad", true))
+}
+
+// Test numeric getters
+func TestGetNumbers(t *testing.T) {
+	file, err := testParse(typedExample)
+	require.NoError(t, err)
+
+	i, err := file.GetInt("types", "int")
+	assert.NoError(t, err)
+	assert.Equal(t, -42, i)
+
+	i, err = file.GetInt("types", "hex")
+	assert.NoError(t, err)
+	assert.Equal(t, 31, i)
+
+	i64, err := file.GetInt64("types", "big")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9000000000), i64)
+
+	u, err := file.GetUint("types", "hex")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(31), u)
+
+	_, err = file.GetUint("types", "int")
+	assert.Error(t, err)
+
+	fl, err := file.GetFloat("types", "float")
+	assert.NoError(t, err)
+	assert.Equal(t, 3.25, fl)
+
+	assert.Equal(t, 7, file.GetIntDefault("types", "bad", 7))
+	assert.Equal(t, int64(7), file.GetInt64Default("types", "missing", 7))
+	assert.Equal(t, uint(7), file.GetUintDefault("types", "int", 7))
+	assert.Equal(t, 0.5, file.GetFloatDefault("missing", "float", 0.5))
+}
+
+// Test integers are parsed in base 10 unless prefixed
+func TestGetIntegerBases(t *testing.T) {
+	file, err := testParse("[n]\ndecimal = 10\nleading = 010\nport = 08080\nprefixed = 0o10\nbinary = 0b101\nhex = 0xff\nsigned = -0x10\nseparated = 1_000\nbad = 0xzz\n")
+	require.NoError(t, err)
+
+	cases := map[string]int{"decimal": 10, "leading": 10, "port": 8080, "prefixed": 8, "binary": 5, "hex": 255}
+	for key, expected := range cases {
+		i, err := file.GetInt("n", key)
+		require.NoError(t, err, key)
+		assert.Equal(t, expected, i, key)
+		u, err := file.GetUint("n", key)
+		require.NoError(t, err, key)
+		assert.Equal(t, uint(expected), u, key)
+	}
+	i, err := file.GetInt64("n", "signed")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-16), i)
+	_, err = file.GetUint("n", "signed")
+	assert.Error(t, err)
+
+	for _, key := range []string{"separated", "bad"} {
+		_, err = file.GetInt("n", key)
+		assert.Error(t, err, key)
+	}
+	_, err = file.GetInt("n", "bad")
+	assert.ErrorContains(t, err, `parsing "0xzz"`)
+}
+
+// Test durations and times
+func TestGetDurationAndTime(t *testing.T) {
+	file, err := testParse(typedExample)
+	require.NoError(t, err)
+
+	d, err := file.GetDuration("types", "duration")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+	assert.Equal(t, time.Second, file.GetDurationDefault("types", "int", time.Second))
+
+	tm, err := file.GetTime("types", "time", time.RFC3339)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), tm)
+
+	def := time.Unix(0, 0)
+	assert.Equal(t, def, file.GetTimeDefault("types", "bad", time.RFC3339, def))
+}
+
+// Test lists are split and trimmed
+func TestGetStringSlice(t *testing.T) {
+	file, err := testParse(typedExample)
+	require.NoError(t, err)
+
+	list, err := file.GetStringSlice("types", "list", ",")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, list)
+
+	list, err = file.GetStringSlice("types", "empty", ",")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	assert.Equal(t, []string{"x"}, file.GetStringSliceDefault("types", "missing", ",", []string{"x"}))
+}
+
+// Test errors identify the missing key or the position of the bad value
+func TestGetErrors(t *testing.T) {
+	file, err := testParse(typedExample)
+	require.NoError(t, err)
+
+	_, err = file.GetInt("types", "missing")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = file.GetInt("types", "bad")
+	var valueErr *ValueError
+	require.True(t, errors.As(err, &valueErr))
+	assert.Equal(t, "not a number", valueErr.Value)
+	assert.Equal(t, Position{Line: 13, Column: 6, Offset: 160}, valueErr.Pos)
+	assert.ErrorContains(t, err, "(line:13, col:6)")
+}
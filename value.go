@@ -0,0 +1,481 @@
+package montoya
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Value returns the decoded value
+//
+// Surrounding whitespace is trimmed. Quoted values have their quotes removed
+// and backslash escapes decoded; unquoted values are returned verbatim, so
+// that e.g. Windows paths are left untouched.
+//
+// A value continued on multiple lines is joined into its logical value, see
+// `Options.BackslashContinuation`, `Options.IndentedContinuation` and
+// `Options.TripleQuotes`. Values with embedded quotes and raw values follow
+// their own rules, see `Options.EmbeddedQuotes` and `Options.RawValues`.
+func (w *ValueNode) Value() string {
+	if w == nil {
+		return ""
+	}
+	switch w.syntax {
+	case embeddedValue:
+		return decodeEmbedded(w.content)
+	case rawValue:
+		return decodeRaw(w.content, true)
+	case rawIndentedValue, rawLineValue:
+		return decodeRaw(w.content, false)
+	}
+	return decodeValue(w.content)
+}
+
+// encode encodes a value so that it can be written as the content of the node,
+// on a line whose key is indented by `indent`
+func (w *ValueNode) encode(value string, indent []byte) []byte {
+	switch w.syntax {
+	case embeddedValue:
+		return encodeEmbedded(value)
+	case rawValue:
+		return encodeRaw(value)
+	case rawIndentedValue:
+		return encodeIndented(value, indent)
+	case rawLineValue:
+		return encodeRawLine(value)
+	}
+	return EncodeValue(value)
+}
+
+// decodeValue decodes raw value content as found after the `=` of a KeyValueLine
+func decodeValue(content []byte) string {
+	trimmed := bytes.Trim(content, string(validWhitespaceByteSet))
+	if bytes.HasPrefix(trimmed, tripleQuote) && isClosedQuotedString(valueStringState(trimmed)) {
+		return decodeTripleQuoted(trimmed)
+	}
+	if bytes.IndexByte(content, B_NEWLINE) >= 0 {
+		content = joinContinuations(content)
+		if bytes.IndexByte(content, B_NEWLINE) >= 0 {
+			return joinIndented(content)
+		}
+		trimmed = bytes.Trim(content, string(validWhitespaceByteSet))
+	}
+	if len(trimmed) == 0 || trimmed[0] != B_QUOTE {
+		return string(trimmed)
+	}
+
+	quoted := trimmed[1:]
+	if n := len(quoted); n > 0 && quoted[n-1] == B_QUOTE && isClosedQuotedString(valueStringState(trimmed)) {
+		quoted = quoted[:n-1]
+	}
+	return unescape(quoted)
+}
+
+// decodeTripleQuoted decodes a terminated triple quoted value
+//
+// The content is taken verbatim, save for a newline directly after the opening
+// quotes. Line endings are normalized to a single newline.
+func decodeTripleQuoted(trimmed []byte) string {
+	content := trimmed[len(tripleQuote) : len(trimmed)-len(tripleQuote)]
+	content = bytes.ReplaceAll(content, []byte{B_CR, B_NEWLINE}, []byte{B_NEWLINE})
+	return string(bytes.TrimPrefix(content, []byte{B_NEWLINE}))
+}
+
+// joinContinuations removes every backslash line continuation from the value,
+// leaving escaped backslashes in quoted values intact
+func joinContinuations(content []byte) []byte {
+	joined := make([]byte, 0, len(content))
+	state := VALUE_PARSE_WHITESPACE
+	for i := 0; i < len(content); i++ {
+		if n := continuationLength(content[i:]); n > 0 && state != VALUE_PARSE_QUOTED_BACKSLASH {
+			i += n - 1
+			continue
+		}
+		state = nextValueState(state, content[i])
+		joined = append(joined, content[i])
+	}
+	return joined
+}
+
+// joinIndented joins the lines of a value continued on indented lines,
+// trimming every line
+func joinIndented(content []byte) string {
+	lines := bytes.Split(content, []byte{B_NEWLINE})
+	for i, line := range lines {
+		lines[i] = bytes.Trim(line, string(validWhitespaceByteSet))
+	}
+	return string(bytes.Join(lines, []byte{B_NEWLINE}))
+}
+
+// unescape decodes the backslash escapes in the content of a quoted value
+//
+// Unknown or malformed escapes are kept verbatim.
+func unescape(content []byte) string {
+	if bytes.IndexByte(content, B_BACKSLASH) < 0 {
+		return string(content)
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] != B_BACKSLASH || i+1 == len(content) {
+			out.WriteByte(content[i])
+			continue
+		}
+		i++
+		switch content[i] {
+		case B_QUOTE, B_BACKSLASH:
+			out.WriteByte(content[i])
+		case 'n':
+			out.WriteByte(B_NEWLINE)
+		case 't':
+			out.WriteByte(B_TAB)
+		case 'r':
+			out.WriteByte(B_CR)
+		case 'u':
+			r, size := unescapeUnicode(content[i+1:])
+			if size == 0 {
+				out.WriteByte(B_BACKSLASH)
+				out.WriteByte(content[i])
+				break
+			}
+			out.WriteRune(r)
+			i += size
+		default:
+			out.WriteByte(B_BACKSLASH)
+			out.WriteByte(content[i])
+		}
+	}
+	return out.String()
+}
+
+// decodeEmbedded decodes raw value content with embedded quotes, see
+// `Options.EmbeddedQuotes`
+//
+// Like git config does, quotes are removed wherever they are, backslash
+// escapes are decoded inside and outside of quotes and whitespace outside of
+// quotes is trimmed, with every inner whitespace byte written as a space.
+func decodeEmbedded(content []byte) string {
+	var out []byte
+	quoted, space := false, 0
+	for i := 0; i < len(content); i++ {
+		if n := continuationLength(content[i:]); n > 0 {
+			i += n - 1
+			continue
+		}
+		b := content[i]
+		if isWhitespaceByte(b) && !quoted {
+			if len(out) > 0 {
+				space++
+			}
+			continue
+		}
+		for ; space > 0; space-- {
+			out = append(out, B_SPACE)
+		}
+		switch {
+		case b == B_QUOTE:
+			quoted = !quoted
+		case b == B_BACKSLASH && i+1 < len(content):
+			i++
+			out = append(out, unescapeEmbedded(content[i]))
+		default:
+			out = append(out, b)
+		}
+	}
+	return string(out)
+}
+
+// unescapeEmbedded decodes the byte after a backslash in a value with embedded
+// quotes, which is taken literally unless it is a known escape
+func unescapeEmbedded(b byte) byte {
+	switch b {
+	case 'n':
+		return B_NEWLINE
+	case 't':
+		return B_TAB
+	case 'b':
+		return '\b'
+	}
+	return b
+}
+
+// decodeRaw decodes raw value content, see `Options.RawValues`
+//
+// Like systemd does, a backslash line continuation is replaced by a space, if
+// `backslashes` is set. Otherwise a trailing backslash is part of the value.
+// Like configparser does, the lines of an indented continuation are trimmed
+// and joined by newlines. The whitespace at the start of a continued line is
+// dropped either way.
+func decodeRaw(content []byte, backslashes bool) string {
+	whitespace := string(validWhitespaceByteSet)
+	lines := bytes.Split(content, []byte{B_NEWLINE})
+	var out []byte
+	for i, line := range lines {
+		if i > 0 {
+			line = bytes.TrimLeft(line, whitespace)
+		}
+		if i == len(lines)-1 {
+			out = append(out, line...)
+			break
+		}
+		line = bytes.TrimSuffix(line, []byte{B_CR})
+		if backslashes && endsInRawContinuation(line) {
+			out = append(append(out, line[:len(line)-1]...), B_SPACE)
+		} else {
+			out = append(append(out, bytes.TrimRight(line, whitespace)...), B_NEWLINE)
+		}
+	}
+	return string(bytes.Trim(out, whitespace))
+}
+
+// unescapeSubsection decodes the backslash escapes in a subsection name, which
+// take the byte after the backslash literally
+func unescapeSubsection(content []byte) string {
+	var out strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] == B_BACKSLASH && i+1 < len(content) {
+			i++
+		}
+		out.WriteByte(content[i])
+	}
+	return out.String()
+}
+
+// unescapeUnicode decodes the hex digits of a `\uXXXX` escape, including a
+// following low surrogate escape if the first one is a high surrogate
+//
+// Returns the number of bytes consumed after the `u`, or 0 if malformed.
+func unescapeUnicode(content []byte) (rune, int) {
+	r, ok := parseHex4(content)
+	if !ok {
+		return 0, 0
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, 4
+	}
+	rest := content[4:]
+	if len(rest) >= 2 && rest[0] == B_BACKSLASH && rest[1] == 'u' {
+		if low, ok := parseHex4(rest[2:]); ok {
+			if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+				return pair, 10
+			}
+		}
+	}
+	return utf8.RuneError, 4
+}
+
+// parseHex4 parses 4 hexadecimal digits into a rune
+func parseHex4(content []byte) (rune, bool) {
+	if len(content) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(content[:4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// EncodeValue encodes a value so that it can be written after the `=` of a KeyValueLine
+//
+// Values that can be represented unquoted are returned as is. Any other value
+// is quoted, with quotes, backslashes and control characters escaped.
+func EncodeValue(value string) []byte {
+	if !needsQuoting(value) {
+		return []byte(value)
+	}
+
+	out := []byte{B_QUOTE}
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		switch b {
+		case B_QUOTE, B_BACKSLASH:
+			out = append(out, B_BACKSLASH, b)
+		case B_NEWLINE:
+			out = append(out, B_BACKSLASH, 'n')
+		case B_TAB:
+			out = append(out, B_BACKSLASH, 't')
+		case B_CR:
+			out = append(out, B_BACKSLASH, 'r')
+		default:
+			if b < 0x20 || b == 0x7F {
+				out = fmt.Appendf(out, "\\u%04x", b)
+				break
+			}
+			// any other byte, including non-ASCII, is copied verbatim
+			out = append(out, b)
+		}
+	}
+	return append(out, B_QUOTE)
+}
+
+// encodeEmbedded encodes a value to be written as a value with embedded
+// quotes, see `Options.EmbeddedQuotes`
+//
+// Quotes, backslashes and control characters are escaped. The value is quoted
+// if it contains comment symbols or runs of whitespace, or starts or ends
+// with whitespace.
+func encodeEmbedded(value string) []byte {
+	quote := strings.ContainsAny(value, "#;") || strings.Contains(value, "  ") ||
+		value != "" && (isWhitespaceByte(value[0]) || isWhitespaceByte(value[len(value)-1]))
+
+	var out []byte
+	if quote {
+		out = append(out, B_QUOTE)
+	}
+	for i := 0; i < len(value); i++ {
+		switch b := value[i]; b {
+		case B_QUOTE, B_BACKSLASH:
+			out = append(out, B_BACKSLASH, b)
+		case B_NEWLINE:
+			out = append(out, B_BACKSLASH, 'n')
+		case B_TAB:
+			out = append(out, B_BACKSLASH, 't')
+		case '\b':
+			out = append(out, B_BACKSLASH, 'b')
+		default:
+			out = append(out, b)
+		}
+	}
+	if quote {
+		out = append(out, B_QUOTE)
+	}
+	return out
+}
+
+// encodeRaw encodes a value to be written as a raw value, see `Options.RawValues`
+//
+// The value is written verbatim, save for newlines which become line
+// continuations, so they decode as spaces. A line ending in an odd number of
+// backslashes gets another one, which systemd reads as an escaped backslash
+// instead of a line continuation.
+func encodeRaw(value string) []byte {
+	lines := strings.Split(value, "\n")
+	var out []byte
+	for i, line := range lines {
+		out = append(out, line...)
+		if endsInRawContinuation([]byte(line)) {
+			out = append(out, B_BACKSLASH)
+		}
+		if i < len(lines)-1 {
+			out = append(out, B_BACKSLASH, B_NEWLINE)
+		}
+	}
+	return out
+}
+
+// encodeRawLine encodes a value to be written as a raw value that cannot
+// continue on another line, see `Options.RawValues`
+//
+// The value is written verbatim, save for newlines and carriage returns which
+// are escaped as `\n` and `\r`, like desktop entries do, so they cannot start
+// another line. Backslashes are left as they are.
+func encodeRawLine(value string) []byte {
+	out := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		switch b := value[i]; b {
+		case B_NEWLINE:
+			out = append(out, B_BACKSLASH, 'n')
+		case B_CR:
+			out = append(out, B_BACKSLASH, 'r')
+		default:
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// endsInRawContinuation checks if a line of a raw value ends in an odd number
+// of backslashes, the last of which continues the value on the next line
+//
+// Like systemd, a pair of backslashes is an escaped backslash.
+func endsInRawContinuation(line []byte) bool {
+	n := len(line) - len(bytes.TrimRight(line, string(B_BACKSLASH)))
+	return n%2 == 1
+}
+
+// encodeIndented encodes a value to be written as a raw value that continues
+// on indented lines, see `Options.IndentedContinuation`
+//
+// Like configparser writes them, the lines after the first are indented by a
+// tab more than the key. Lines that would end the value are dropped: blank
+// lines, and lines starting with a comment symbol or a carriage return, which
+// IniFile.Set rejects, see isIndentable.
+func encodeIndented(value string, indent []byte) []byte {
+	lines := strings.Split(value, "\n")
+	out := []byte(lines[0])
+	for _, line := range lines[1:] {
+		if endsIndented(line) {
+			continue
+		}
+		out = append(out, B_NEWLINE)
+		out = append(out, indent...)
+		out = append(out, B_TAB)
+		out = append(out, line...)
+	}
+	return out
+}
+
+// isIndentable checks if every line of `value` after the first is either
+// blank or can be written as an indented continuation line
+func isIndentable(value string) bool {
+	lines := strings.Split(value, "\n")
+	for _, line := range lines[1:] {
+		if endsIndented(line) && strings.Trim(line, string(validWhitespaceByteSet)) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// endsIndented checks if `line`, written as an indented continuation line,
+// would end the value instead, as a blank line or a comment does
+func endsIndented(line string) bool {
+	line = strings.TrimLeft(line, string([]byte{B_SPACE, B_TAB}))
+	return line == "" || line[0] == B_CR || convertToken(line[0]) == CommentStart
+}
+
+// quoteSubsection quotes a subsection name, escaping quotes and backslashes
+func quoteSubsection(name string) []byte {
+	out := []byte{B_QUOTE}
+	for i := 0; i < len(name); i++ {
+		if name[i] == B_QUOTE || name[i] == B_BACKSLASH {
+			out = append(out, B_BACKSLASH)
+		}
+		out = append(out, name[i])
+	}
+	return append(out, B_QUOTE)
+}
+
+// needsQuoting returns if `value` would not decode to itself when written unquoted
+func needsQuoting(value string) bool {
+	if value == "" {
+		return false
+	}
+	if isWhitespaceByte(value[0]) || isWhitespaceByte(value[len(value)-1]) {
+		return true
+	}
+	// a trailing backslash would continue the value on the next line
+	if value[len(value)-1] == B_BACKSLASH {
+		return true
+	}
+	for i := 0; i < len(value); i++ {
+		if !isUnquotedValueByte(value[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWhitespaceByte checks if the input is whitespace
+func isWhitespaceByte(input byte) bool {
+	return convertToken(input) == Whitespace
+}
+
+// isUnquotedValueByte checks if the input may be present in an unquoted value
+func isUnquotedValueByte(input byte) bool {
+	return !invalidValueTableUnquoted[input]
+}
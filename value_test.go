@@ -0,0 +1,74 @@
+package montoya
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test raw values decode to their logical value
+func TestDecodeValue(t *testing.T) {
+	cases := map[string]string{
+		"":                          "",
+		"   ":                       "",
+		" plain value \t\r":         "plain value",
+		`C:\path\to`:                `C:\path\to`,
+		` "quoted" `:                "quoted",
+		`"  padded  "`:              "  padded  ",
+		`""`:                        "",
+		`"with \"escapes\" \\ end"`: `with "escapes" \ end`,
+		`"a\nb\tc\rd"`:              "a\nb\tc\rd",
+		`"\u00e9\u4e2d"`:            "é中",
+		`"\ud83d\ude00"`:            "😀",
+		`"\uzzzz \q"`:               `\uzzzz \q`,
+		`"# not ; a comment"`:       "# not ; a comment",
+	}
+
+	for raw, expected := range cases {
+		assert.Equal(t, expected, decodeValue([]byte(raw)), "raw: %q", raw)
+	}
+}
+
+// Test values are quoted only when required
+func TestEncodeValue(t *testing.T) {
+	cases := map[string]string{
+		"":                "",
+		"plain":           "plain",
+		`C:\path`:         `C:\path`,
+		" padded":         `" padded"`,
+		"a # b":           `"a # b"`,
+		`say "hi"`:        `"say \"hi\""`,
+		"multi\nline":     `"multi\nline"`,
+		"nul\x00":         `"nul\u0000"`,
+		`"leading quote`:  `"\"leading quote"`,
+		`back\slash; end`: `"back\\slash; end"`,
+		`C:\dir\`:         `"C:\\dir\\"`,
+	}
+
+	for value, expected := range cases {
+		assert.Equal(t, expected, string(EncodeValue(value)), "value: %q", value)
+	}
+}
+
+// Test encoded values decode to the original
+func TestEncodeDecodeValue(t *testing.T) {
+	for range 500 {
+		value := string(fuzzFromSet(invertByteSet(nil)))
+		encoded := EncodeValue(value)
+		assert.NotEqual(t, VALUE_PARSE_ERROR, valueStringState(encoded), "value: %q", value)
+		assert.Equal(t, value, decodeValue(encoded), "value: %q", value)
+	}
+}
+
+// Test the Value accessor on parsed lines
+func TestValueAccessor(t *testing.T) {
+	file, err := testParse("a = \"quoted \\\"value\\\"\" ; comment\nb =\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, `quoted "value"`, file.Head.(*KeyValueLine).Value.Value())
+	assert.Equal(t, "", file.Tail.(*KeyValueLine).Value.Value())
+
+	var missing *ValueNode
+	assert.Equal(t, "", missing.Value())
+}